@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/teablog/tea/internal/db"
 	"gorm.io/gorm"
+	"reflect"
 )
 
 type DAO struct {
@@ -13,19 +14,9 @@ type DAO struct {
 func (dao *DAO) SelectByPage(ctx context.Context, list interface{}, searchOpt ...SessionOption) (int64, error) {
 	var err error
 
-	needCount := true
-	for _, opt := range searchOpt {
-		if opt.Type == sessionOptionNoCount {
-			needCount = false
-		}
-	}
 	count := int64(0)
-	if needCount {
-		session := db.DB.Session(&gorm.Session{})
-		for _, opt := range searchOpt {
-			session = processSessionOptionForCount(opt, session)
-		}
-		res := session.Count(&count)
+	if needCount(searchOpt) {
+		res := countSession(searchOpt).Count(&count)
 		if res.Error != nil {
 			return 0, errors.Wrapf(res.Error, "{{查询条数失败!}}")
 		}
@@ -46,6 +37,36 @@ func (dao *DAO) SelectByPage(ctx context.Context, list interface{}, searchOpt ..
 	return count, nil
 }
 
+func needCount(searchOpt []SessionOption) bool {
+	for _, opt := range searchOpt {
+		if opt.Type == sessionOptionNoCount {
+			return false
+		}
+	}
+	return true
+}
+
+// countSession SelectByPage 统计条数使用的 session
+func countSession(searchOpt []SessionOption) *gorm.DB {
+	session := db.DB.Session(&gorm.Session{})
+	for _, opt := range searchOpt {
+		session = processSessionOptionForCount(opt, session)
+	}
+	return applyCountJoins(session)
+}
+
+// countQuery Count 使用的 session，设置了 CountProcess 的条件（如 WithMatch 的评分列和排序、join）只应用计数部分
+func countQuery(session *gorm.DB, searchOpt []SessionOption) *gorm.DB {
+	for _, opt := range searchOpt {
+		if opt.CountProcess != nil {
+			session = opt.CountProcess(session)
+			continue
+		}
+		session = processSessionOption(opt, session)
+	}
+	return applyCountJoins(session)
+}
+
 // Select 单个查询尽量用get，因为select返回的不是nil 是id=0的对象
 func (dao *DAO) Select(ctx context.Context, list interface{}, searchOpt ...SessionOption) error {
 	session, err := Session.getFromContext(ctx)
@@ -87,11 +108,8 @@ func (dao *DAO) Count(ctx context.Context, searchOpt ...SessionOption) (int64, e
 	if err != nil {
 		return 0, err
 	}
-	for _, opt := range searchOpt {
-		sess = processSessionOption(opt, sess)
-	}
 	count := int64(0)
-	res := sess.Count(&count)
+	res := countQuery(sess, searchOpt).Count(&count)
 	if res.Error != nil {
 		return 0, errors.Wrapf(res.Error, "{{查询失败}}")
 	}
@@ -107,12 +125,41 @@ func (dao *DAO) Updates(ctx context.Context, data interface{}, searchOpt ...Sess
 	for _, opt := range searchOpt {
 		session = opt.Process(session)
 	}
-	res := session.Updates(data)
-	if res.Error != nil {
-		return 0, res.Error
+	if err := checkWhere(session, data); err != nil {
+		return 0, err
+	}
+	lock, err := getOptimisticLock(session, data)
+	if err != nil {
+		return 0, err
+	}
+	if lock != nil {
+		if session, data, err = lock.apply(session, data); err != nil {
+			return 0, err
+		}
+	}
+	rows, err := guardedExec(session, func(tx *gorm.DB) *gorm.DB {
+		return tx.Updates(data)
+	})
+	if err != nil {
+		if lock != nil && lock.rollback != nil {
+			lock.rollback()
+		}
+		return 0, err
+	}
+	if lock != nil && rows == 0 {
+		return 0, lock.stale()
 	}
 
-	return res.RowsAffected, res.Error
+	return rows, nil
+}
+
+// UpdatesFromStruct 按 dto 的 session tag 生成更新的列（见 BuildUpdateMap）后调用 Updates
+func (dao *DAO) UpdatesFromStruct(ctx context.Context, model interface{}, dto interface{}, searchOpt ...SessionOption) (int64, error) {
+	values := BuildUpdateMap(dto)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return dao.Updates(ctx, values, append([]SessionOption{SessionCondition.WithModel(model)}, searchOpt...)...)
 }
 
 func (dao *DAO) Insert(ctx context.Context, data interface{}, searchOpt ...SessionOption) (int64, error) {
@@ -124,6 +171,9 @@ func (dao *DAO) Insert(ctx context.Context, data interface{}, searchOpt ...Sessi
 		session = opt.Process(session)
 	}
 	res := session.Create(data)
+	if session.DryRun {
+		return 0, newDryRunError(res)
+	}
 	if res.Error != nil {
 		return 0, res.Error
 	}
@@ -138,8 +188,55 @@ func (dao *DAO) Delete(ctx context.Context, data interface{}, searchOpt ...Sessi
 	for _, opt := range searchOpt {
 		session = opt.Process(session)
 	}
-	res := session.Delete(data)
-	return res.RowsAffected, res.Error
+	if err := checkWhere(session, data); err != nil {
+		return 0, err
+	}
+	return guardedExec(session, func(tx *gorm.DB) *gorm.DB {
+		return tx.Delete(data)
+	})
+}
+
+// ForceDelete 物理删除，忽略软删除
+func (dao *DAO) ForceDelete(ctx context.Context, data interface{}, searchOpt ...SessionOption) (int64, error) {
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	session = session.Unscoped()
+	for _, opt := range searchOpt {
+		session = opt.Process(session)
+	}
+	if err := checkWhere(session, data); err != nil {
+		return 0, err
+	}
+	return guardedExec(session, func(tx *gorm.DB) *gorm.DB {
+		return tx.Delete(data)
+	})
+}
+
+// Restore 恢复已软删除的数据，model 没有 gorm.DeletedAt 字段时返回 ErrNotSoftDelete
+func (dao *DAO) Restore(ctx context.Context, model interface{}, searchOpt ...SessionOption) (int64, error) {
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	session = session.Unscoped().Model(model)
+	if err := session.Statement.Parse(model); err != nil {
+		return 0, errors.Wrapf(err, "{{恢复失败}}")
+	}
+	field := deletedAtField(session.Statement.Schema)
+	if field == nil {
+		return 0, errors.Wrapf(ErrNotSoftDelete, "%T", model)
+	}
+	for _, opt := range searchOpt {
+		session = opt.Process(session)
+	}
+	if err := checkWhere(session, model); err != nil {
+		return 0, err
+	}
+	return guardedExec(session, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(trashedCondition{}).Update(field.DBName, nil)
+	})
 }
 
 func (dao *DAO) Pluck(ctx context.Context, field string, data []interface{}, searchOpt ...SessionOption) error {
@@ -154,6 +251,7 @@ func (dao *DAO) Pluck(ctx context.Context, field string, data []interface{}, sea
 	return res.Error
 }
 
+// Save 按主键插入或更新，WithUpdateCols 可以限制更新的列，返回保存的行数（MySQL 更新时不再计为 2 行）
 func (dao *DAO) Save(ctx context.Context, data interface{}, searchOpt ...SessionOption) (int64, error) {
 	session, err := Session.getFromContext(ctx)
 	if err != nil {
@@ -162,23 +260,71 @@ func (dao *DAO) Save(ctx context.Context, data interface{}, searchOpt ...Session
 	for _, opt := range searchOpt {
 		session = opt.Process(session)
 	}
-	res := session.Create(data)
+
+	rows := int64(1)
+	value := GetElem(reflect.ValueOf(data))
+	isSlice := value.Kind() == reflect.Slice || value.Kind() == reflect.Array
+	if isSlice {
+		rows = int64(value.Len())
+	}
+
+	columns := session.Statement.Selects
+	if len(columns) == 0 {
+		res := session.Save(data)
+		if session.DryRun {
+			return 0, newDryRunError(res)
+		}
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		return saveRowsAffected(res, rows), nil
+	}
+
+	session.Statement.Selects = nil
+	if err := session.Statement.Parse(data); err != nil {
+		return 0, errors.Wrapf(err, "{{保存失败}}")
+	}
+	session = session.Session(&gorm.Session{})
+	if !isSlice {
+		for _, pf := range session.Statement.Schema.PrimaryFields {
+			if _, zero := pf.ValueOf(session.Statement.Context, value); zero {
+				res := session.Create(data)
+				if session.DryRun {
+					return 0, newDryRunError(res)
+				}
+				return res.RowsAffected, res.Error
+			}
+		}
+		res := session.Select(columns).Save(data)
+		if session.DryRun {
+			return 0, newDryRunError(res)
+		}
+		if res.Error != nil || res.RowsAffected != 0 {
+			return res.RowsAffected, res.Error
+		}
+	}
+
+	spec := UpsertSpec{ConflictColumns: session.Statement.Schema.PrimaryFieldDBNames, UpdateColumns: columns}
+	res := SessionCondition.WithUpsert(spec).Process(session).Create(data)
+	if session.DryRun {
+		return 0, newDryRunError(res)
+	}
 	if res.Error != nil {
 		return 0, res.Error
 	}
-	return res.RowsAffected, res.Error
+	return saveRowsAffected(res, rows), nil
 }
 
-func (dao *DAO) CreateInBatches(ctx context.Context, data interface{}, options ...SessionOption) error {
-	session, err := Session.getFromContext(ctx)
-	if err != nil {
-		return err
+func saveRowsAffected(res *gorm.DB, rows int64) int64 {
+	result := getDialect(res).UpsertResult(UpsertSpec{}, rows, res.RowsAffected)
+	if result.CountsReported {
+		return result.Inserted + result.Updated
 	}
-	for _, opt := range options {
-		session = opt.Process(session)
+	// MySQL 更新的行计 2 行，无法区分时不超过保存的行数
+	if result.RowsAffected > rows {
+		return rows
 	}
-	res := session.CreateInBatches(data, 100)
-	return res.Error
+	return result.RowsAffected
 }
 
 func (dao *DAO) Transaction(ctx context.Context, actions ...func(ctx context.Context) error) error {
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+)
+
+const (
+	AggregateSum   = "SUM"
+	AggregateAvg   = "AVG"
+	AggregateMin   = "MIN"
+	AggregateMax   = "MAX"
+	AggregateCount = "COUNT"
+)
+
+var (
+	aggregateAliasRegexp = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+	groupAliasRegexp     = regexp.MustCompile(`(?i)\s+as\s+`)
+)
+
+// AggregateField 聚合函数，Alias 为空时使用 func_column
+type AggregateField struct {
+	Func   string
+	Column string
+	Alias  string
+}
+
+func Sum(column string, alias ...string) AggregateField {
+	return newAggregateField(AggregateSum, column, alias)
+}
+
+func Avg(column string, alias ...string) AggregateField {
+	return newAggregateField(AggregateAvg, column, alias)
+}
+
+func Min(column string, alias ...string) AggregateField {
+	return newAggregateField(AggregateMin, column, alias)
+}
+
+func Max(column string, alias ...string) AggregateField {
+	return newAggregateField(AggregateMax, column, alias)
+}
+
+func Count(column string, alias ...string) AggregateField {
+	return newAggregateField(AggregateCount, column, alias)
+}
+
+func newAggregateField(fn string, column string, alias []string) AggregateField {
+	field := AggregateField{Func: fn, Column: column}
+	if len(alias) != 0 {
+		field.Alias = alias[0]
+	}
+	return field
+}
+
+func (f AggregateField) name() string {
+	if len(f.Alias) != 0 {
+		return f.Alias
+	}
+	return strings.Trim(aggregateAliasRegexp.ReplaceAllString(strings.ToLower(f.Func+"_"+f.Column), "_"), "_")
+}
+
+func (f AggregateField) build() (string, error) {
+	switch strings.ToUpper(f.Func) {
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregateCount:
+	default:
+		return "", errors.Errorf("Aggregate fail: unknown func %s", f.Func)
+	}
+	return fmt.Sprintf("%s(%s) AS %s", strings.ToUpper(f.Func), f.Column, f.name()), nil
+}
+
+// AggregateSpec GroupBy 中可以使用 "DATE(created_at) AS day"，select 时带上别名，group by 时去掉别名
+type AggregateSpec struct {
+	Aggregates []AggregateField
+	GroupBy    []string
+	Having     string
+	HavingArgs []interface{}
+}
+
+func (spec AggregateSpec) build(session *gorm.DB) (*gorm.DB, error) {
+	if len(spec.Aggregates) == 0 {
+		return nil, errors.New("Aggregate fail: no aggregate func")
+	}
+
+	selects := make([]string, 0, len(spec.GroupBy)+len(spec.Aggregates))
+	groups := make([]string, 0, len(spec.GroupBy))
+	grouped := make(map[string]bool, len(spec.GroupBy))
+	for _, group := range spec.GroupBy {
+		selects = append(selects, group)
+		column := groupAliasRegexp.Split(group, 2)[0]
+		if !grouped[column] {
+			grouped[column] = true
+			groups = append(groups, column)
+		}
+	}
+	for _, aggregate := range spec.Aggregates {
+		sql, err := aggregate.build()
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, sql)
+	}
+
+	session = applyCountJoins(session, selects...)
+	session = session.Select(strings.Join(selects, ", "))
+	for _, group := range groups {
+		session = session.Group(group)
+	}
+	if len(spec.Having) != 0 {
+		session = session.Having(spec.Having, spec.HavingArgs...)
+	}
+	return session, nil
+}
+
+func (dao *DAO) aggregateSession(ctx context.Context, spec AggregateSpec, searchOpt []SessionOption) (*gorm.DB, error) {
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range searchOpt {
+		if opt.Type == sessionOptionHaving {
+			session = opt.Process(session)
+			continue
+		}
+		session = processSessionOptionForCount(opt, session)
+	}
+	return spec.build(session)
+}
+
+// Aggregate 聚合查询，复用列表查询的过滤条件（忽略分页、排序、select、group by，having 作用在聚合结果上），每行为 列名 -> 值
+func (dao *DAO) Aggregate(ctx context.Context, spec AggregateSpec, searchOpt ...SessionOption) ([]map[string]interface{}, error) {
+	session, err := dao.aggregateSession(ctx, spec, searchOpt)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0)
+	res := session.Find(&rows)
+	if res.Error != nil {
+		return nil, errors.Wrapf(res.Error, "{{聚合查询失败}}")
+	}
+	return rows, nil
+}
+
+// AggregateInto 同 Aggregate，结果扫描到 rows（struct 切片）中
+func (dao *DAO) AggregateInto(ctx context.Context, spec AggregateSpec, rows interface{}, searchOpt ...SessionOption) error {
+	session, err := dao.aggregateSession(ctx, spec, searchOpt)
+	if err != nil {
+		return err
+	}
+	res := session.Scan(rows)
+	if res.Error != nil {
+		return errors.Wrapf(res.Error, "{{聚合查询失败}}")
+	}
+	return nil
+}
+
+// AggregateMap 单个分组、单个聚合函数时返回 分组值 -> 聚合值
+func AggregateMap[K comparable, V any](ctx context.Context, dao *DAO, spec AggregateSpec, searchOpt ...SessionOption) (map[K]V, error) {
+	if len(spec.GroupBy) != 1 || len(spec.Aggregates) != 1 {
+		return nil, errors.New("AggregateMap fail: need exactly one group by and one aggregate func")
+	}
+
+	type row struct {
+		GroupKey K
+		AggValue V
+	}
+	rows := make([]row, 0)
+	if err := dao.AggregateInto(ctx, spec.keyed(), &rows, searchOpt...); err != nil {
+		return nil, err
+	}
+
+	result := make(map[K]V, len(rows))
+	for _, r := range rows {
+		result[r.GroupKey] = r.AggValue
+	}
+	return result, nil
+}
+
+// keyed AggregateMap 使用的查询，保留调用方的别名（Having 可能引用），另外查出一份 group_key/agg_value 用于扫描
+func (spec AggregateSpec) keyed() AggregateSpec {
+	keyed := spec
+	keyed.GroupBy = []string{spec.GroupBy[0], groupAliasRegexp.Split(spec.GroupBy[0], 2)[0] + " AS group_key"}
+	value := spec.Aggregates[0]
+	value.Alias = "agg_value"
+	keyed.Aggregates = []AggregateField{spec.Aggregates[0], value}
+	return keyed
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func aggregateSQL(t *testing.T, spec AggregateSpec) string {
+	session, err := spec.build(newTestDB(t, DialectMySQL).Session(&gorm.Session{DryRun: true}).Model(&testOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := session.Find(&[]map[string]interface{}{}).Statement
+	return stmt.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
+
+func TestAggregateSpecBuild(t *testing.T) {
+	sql := aggregateSQL(t, AggregateSpec{
+		Aggregates: []AggregateField{Sum("amount"), Count("*", "orders")},
+		GroupBy:    []string{"DATE(created_at) AS day", "status"},
+		Having:     "orders > ?",
+		HavingArgs: []interface{}{1},
+	})
+	want := "SELECT DATE(created_at) AS day, status, SUM(amount) AS sum_amount, COUNT(*) AS orders FROM `test_orders` GROUP BY DATE(created_at),`status` HAVING orders > 1"
+	if sql != want {
+		t.Errorf("got  %s\nwant %s", sql, want)
+	}
+
+	if _, err := (AggregateSpec{Aggregates: []AggregateField{{Func: "MEDIAN", Column: "amount"}}}).build(newTestDB(t, DialectMySQL)); err == nil {
+		t.Errorf("unknown func must fail")
+	}
+}
+
+func TestAggregateMapKeepsAlias(t *testing.T) {
+	sql := aggregateSQL(t, AggregateSpec{
+		Aggregates: []AggregateField{Sum("amount", "total")},
+		GroupBy:    []string{"DATE(created_at) AS day"},
+		Having:     "total > ? AND day > ?",
+		HavingArgs: []interface{}{100, "2026-01-01"},
+	}.keyed())
+	for _, want := range []string{
+		"DATE(created_at) AS day, DATE(created_at) AS group_key",
+		"SUM(amount) AS total, SUM(amount) AS agg_value",
+		"GROUP BY DATE(created_at) HAVING total > 100 AND day > '2026-01-01'",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("sql %q does not contain %q", sql, want)
+		}
+	}
+}
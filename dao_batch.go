@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+const (
+	defaultBatchSize = 100
+	sessionBatchKey  = "gorm_where:batch"
+)
+
+// BatchProgress 每批插入完成后的回调参数，Index 从 0 开始
+type BatchProgress struct {
+	Index        int
+	Total        int
+	Rows         int
+	RowsAffected int64
+	Duration     time.Duration
+}
+
+// BatchError 第 Index 批插入失败，逐批提交时之前的批次已经提交，可以用 WithBatchCommit(Index) 续传
+type BatchError struct {
+	Index        int
+	RowsAffected int64
+	Err          error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d: %s", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BatchError) Cause() error {
+	return e.Err
+}
+
+type batchConfig struct {
+	size       int
+	callback   func(BatchProgress)
+	commitEach bool
+	resumeFrom int
+}
+
+func withBatchConfig(update func(cfg *batchConfig)) SessionOption {
+	return SessionOption{
+		Type: sessionOptionBatch,
+		Process: func(session *gorm.DB) *gorm.DB {
+			cfg := getBatchConfig(session)
+			update(&cfg)
+			return session.Set(sessionBatchKey, cfg)
+		},
+	}
+}
+
+func getBatchConfig(session *gorm.DB) batchConfig {
+	if value, ok := session.Get(sessionBatchKey); ok {
+		return value.(batchConfig)
+	}
+	return batchConfig{size: defaultBatchSize}
+}
+
+// WithBatchSize 每批插入的行数，0 表示按数据库占位符上限自动计算
+func (*_sessionCondition) WithBatchSize(size int) SessionOption {
+	return withBatchConfig(func(cfg *batchConfig) {
+		cfg.size = size
+	})
+}
+
+func (*_sessionCondition) WithBatchCallback(callback func(BatchProgress)) SessionOption {
+	return withBatchConfig(func(cfg *batchConfig) {
+		cfg.callback = callback
+	})
+}
+
+// WithBatchCommit 每批单独提交（默认所有批次在一个事务中），从第 resumeFrom 批开始插入
+func (*_sessionCondition) WithBatchCommit(resumeFrom int) SessionOption {
+	return withBatchConfig(func(cfg *batchConfig) {
+		cfg.commitEach = true
+		cfg.resumeFrom = resumeFrom
+	})
+}
+
+// batchSize 指定了 size 时直接使用，0 时按数据库占位符上限和每行的列数计算
+func batchSize(session *gorm.DB, data interface{}, cfg batchConfig) int {
+	if cfg.size > 0 {
+		return cfg.size
+	}
+
+	limit := getDialect(session).MaxPlaceholders() / batchColumns(session, data)
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// batchColumns 每行的列数，data 无法解析成 model 时（例如 []map[string]interface{}）使用第一行 map 的长度
+func batchColumns(session *gorm.DB, data interface{}) int {
+	columns := 0
+	if err := session.Statement.Parse(data); err == nil {
+		columns = len(session.Statement.Schema.DBNames)
+	} else if value := GetElem(reflect.ValueOf(data)); value.Len() != 0 {
+		row := value.Index(0)
+		if row.Kind() == reflect.Interface {
+			row = row.Elem()
+		}
+		if row = GetElem(row); row.Kind() == reflect.Map {
+			columns = row.Len()
+		}
+	}
+	if columns == 0 {
+		columns = 1
+	}
+	return columns
+}
+
+func (dao *DAO) CreateInBatches(ctx context.Context, data interface{}, options ...SessionOption) (int64, error) {
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, opt := range options {
+		session = opt.Process(session)
+	}
+
+	value := GetElem(reflect.ValueOf(data))
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		res := session.Create(data)
+		if session.DryRun {
+			return 0, newDryRunError(res)
+		}
+		return res.RowsAffected, res.Error
+	}
+
+	cfg := getBatchConfig(session)
+	size := batchSize(session.Session(&gorm.Session{}), data, cfg)
+
+	total := (value.Len() + size - 1) / size
+	batch := func(index int) reflect.Value {
+		end := (index + 1) * size
+		if end > value.Len() {
+			end = value.Len()
+		}
+		return value.Slice(index*size, end)
+	}
+
+	if session.DryRun {
+		results := make([]*gorm.DB, 0, total)
+		for index := cfg.resumeFrom; index < total; index++ {
+			results = append(results, session.Session(&gorm.Session{}).Create(batch(index).Interface()))
+		}
+		return 0, newDryRunError(results...)
+	}
+
+	insert := func(tx *gorm.DB, index int) (int64, error) {
+		begin := time.Now()
+		rows := batch(index)
+		res := tx.Create(rows.Interface())
+		if res.Error != nil {
+			return 0, &BatchError{Index: index, Err: res.Error}
+		}
+		if cfg.callback != nil {
+			cfg.callback(BatchProgress{
+				Index:        index,
+				Total:        total,
+				Rows:         rows.Len(),
+				RowsAffected: res.RowsAffected,
+				Duration:     time.Since(begin),
+			})
+		}
+		return res.RowsAffected, nil
+	}
+
+	affected := int64(0)
+	if cfg.commitEach {
+		for index := cfg.resumeFrom; index < total; index++ {
+			rows, err := insert(session.Session(&gorm.Session{}), index)
+			if err != nil {
+				err.(*BatchError).RowsAffected = affected
+				return affected, err
+			}
+			affected += rows
+		}
+		return affected, nil
+	}
+
+	err = session.Transaction(func(tx *gorm.DB) error {
+		for index := 0; index < total; index++ {
+			rows, err := insert(tx, index)
+			if err != nil {
+				return err
+			}
+			affected += rows
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
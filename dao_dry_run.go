@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ErrDryRun WithDryRun 时写操作不执行，返回 *DryRunError
+var ErrDryRun = errors.New("dry run")
+
+// SQLStatement 生成的 SQL 和绑定参数，Explain 为填入参数后的 SQL，只用于日志
+type SQLStatement struct {
+	SQL     string
+	Vars    []interface{}
+	Explain string
+}
+
+// SQLPreview SelectByPage 会执行的查询，WithNoCount 时 Count 为 nil
+type SQLPreview struct {
+	Select SQLStatement
+	Count  *SQLStatement
+}
+
+// DryRunError WithDryRun 时写操作返回的 SQL，可以用 errors.As 取出
+type DryRunError struct {
+	Statements []SQLStatement
+}
+
+func (e *DryRunError) Error() string {
+	sqls := make([]string, 0, len(e.Statements))
+	for _, statement := range e.Statements {
+		sqls = append(sqls, statement.Explain)
+	}
+	return "dry run: " + strings.Join(sqls, "; ")
+}
+
+func (e *DryRunError) Is(target error) bool {
+	return target == ErrDryRun
+}
+
+// WithDryRun 只生成 SQL 不执行，写操作返回 *DryRunError
+func (*_sessionCondition) WithDryRun() SessionOption {
+	return SessionOption{
+		Type: sessionOptionScope,
+		Process: func(session *gorm.DB) *gorm.DB {
+			return session.Session(&gorm.Session{DryRun: true})
+		},
+	}
+}
+
+// ToSQL 返回 SelectByPage 会执行的查询和统计 SQL，不访问数据库
+func (dao *DAO) ToSQL(ctx context.Context, list interface{}, searchOpt ...SessionOption) (SQLPreview, error) {
+	preview := SQLPreview{}
+
+	if needCount(searchOpt) {
+		count := int64(0)
+		res := countSession(searchOpt).Session(&gorm.Session{DryRun: true}).Count(&count)
+		if res.Error != nil {
+			return SQLPreview{}, errors.Wrapf(res.Error, "{{生成SQL失败}}")
+		}
+		statement := newSQLStatement(res)
+		preview.Count = &statement
+	}
+
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return SQLPreview{}, err
+	}
+	session = session.Session(&gorm.Session{DryRun: true})
+	for _, opt := range searchOpt {
+		session = processSessionOption(opt, session)
+	}
+	res := session.Find(list)
+	if res.Error != nil {
+		return SQLPreview{}, errors.Wrapf(res.Error, "{{生成SQL失败}}")
+	}
+	preview.Select = newSQLStatement(res)
+
+	return preview, nil
+}
+
+func newSQLStatement(res *gorm.DB) SQLStatement {
+	sql := res.Statement.SQL.String()
+	return SQLStatement{
+		SQL:     sql,
+		Vars:    res.Statement.Vars,
+		Explain: res.Dialector.Explain(sql, res.Statement.Vars...),
+	}
+}
+
+// newDryRunError 生成 SQL 失败时返回原错误
+func newDryRunError(results ...*gorm.DB) error {
+	err := &DryRunError{}
+	for _, res := range results {
+		if res.Error != nil {
+			return res.Error
+		}
+		err.Statements = append(err.Statements, newSQLStatement(res))
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+)
+
+const sessionGuardKey = "gorm_where:write_guard"
+
+// ErrMissingWhere Updates、Delete、Restore 没有任何条件，需要更新整张表时使用 WithAllowFullTable
+var ErrMissingWhere = errors.New("missing where conditions")
+
+// ErrTooManyRowsAffected 写操作影响的行数超过 WithMaxAffected 的上限，已经回滚
+var ErrTooManyRowsAffected = errors.New("too many rows affected")
+
+type writeGuard struct {
+	allowFullTable bool
+	maxAffected    int64
+}
+
+func withWriteGuard(update func(guard *writeGuard)) SessionOption {
+	return SessionOption{
+		Type: sessionOptionGuard,
+		Process: func(session *gorm.DB) *gorm.DB {
+			guard := getWriteGuard(session)
+			update(&guard)
+			return session.Set(sessionGuardKey, guard)
+		},
+	}
+}
+
+func getWriteGuard(session *gorm.DB) writeGuard {
+	if value, ok := session.Get(sessionGuardKey); ok {
+		return value.(writeGuard)
+	}
+	return writeGuard{}
+}
+
+// WithAllowFullTable 允许没有条件的 Updates、Delete，同时打开 gorm 的 AllowGlobalUpdate
+func (*_sessionCondition) WithAllowFullTable() SessionOption {
+	option := withWriteGuard(func(guard *writeGuard) {
+		guard.allowFullTable = true
+	})
+	process := option.Process
+	option.Process = func(session *gorm.DB) *gorm.DB {
+		return process(session).Session(&gorm.Session{AllowGlobalUpdate: true})
+	}
+	return option
+}
+
+// WithMaxAffected 写操作影响的行数超过 max 时回滚并返回 ErrTooManyRowsAffected
+func (*_sessionCondition) WithMaxAffected(max int64) SessionOption {
+	return withWriteGuard(func(guard *writeGuard) {
+		guard.maxAffected = max
+	})
+}
+
+// checkWhere 处理完 option 的 session 没有 where 条件并且 data、model 都没有主键时返回 ErrMissingWhere
+func checkWhere(session *gorm.DB, data interface{}) error {
+	if getWriteGuard(session).allowFullTable || hasWhere(session) {
+		return nil
+	}
+	if hasPrimaryKey(session, data) || hasPrimaryKey(session, session.Statement.Model) {
+		return nil
+	}
+	return errors.Wrapf(ErrMissingWhere, "%T", data)
+}
+
+func hasWhere(session *gorm.DB) bool {
+	c, ok := session.Statement.Clauses["WHERE"]
+	if !ok {
+		return false
+	}
+	where, ok := c.Expression.(clause.Where)
+	return ok && len(where.Exprs) != 0
+}
+
+// hasPrimaryKey value 是 struct 或 struct 切片且主键不为零值，gorm 会用主键作为条件
+func hasPrimaryKey(session *gorm.DB, value interface{}) bool {
+	elem := GetElem(reflect.ValueOf(value))
+	if elem.Kind() != reflect.Struct && elem.Kind() != reflect.Slice && elem.Kind() != reflect.Array {
+		return false
+	}
+
+	stmt := &gorm.Statement{DB: session}
+	if err := stmt.Parse(value); err != nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+
+	rows := []reflect.Value{elem}
+	if elem.Kind() != reflect.Struct {
+		rows = rows[:0]
+		for i := 0; i < elem.Len(); i++ {
+			rows = append(rows, GetElem(elem.Index(i)))
+		}
+	}
+	for _, row := range rows {
+		if row.Kind() != reflect.Struct {
+			continue
+		}
+		for _, field := range stmt.Schema.PrimaryFields {
+			if _, zero := field.ValueOf(session.Statement.Context, row); !zero {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// guardedExec 执行写操作，设置了 WithMaxAffected 时在事务中执行，超过上限回滚，WithDryRun 时返回 *DryRunError
+func guardedExec(session *gorm.DB, exec func(tx *gorm.DB) *gorm.DB) (int64, error) {
+	if session.DryRun {
+		return 0, newDryRunError(exec(session))
+	}
+
+	guard := getWriteGuard(session)
+	if guard.maxAffected <= 0 {
+		res := exec(session)
+		return res.RowsAffected, res.Error
+	}
+
+	var rows int64
+	err := session.Transaction(func(tx *gorm.DB) error {
+		res := exec(tx)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > guard.maxAffected {
+			return errors.Wrapf(ErrTooManyRowsAffected, "%d > %d", res.RowsAffected, guard.maxAffected)
+		}
+		rows = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHasPrimaryKey(t *testing.T) {
+	session := newTestDB(t, DialectMySQL)
+	cases := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{"struct", &testOrder{ID: 1}, true},
+		{"zero struct", &testOrder{Amount: 10}, false},
+		{"slice", []testOrder{{}, {ID: 2}}, true},
+		{"pointer slice", &[]*testOrder{{ID: 2}}, true},
+		{"zero slice", []testOrder{{}}, false},
+		{"empty slice", []testOrder{}, false},
+		{"map", map[string]interface{}{"id": 1}, false},
+		{"nil", nil, false},
+	}
+	for _, c := range cases {
+		if got := hasPrimaryKey(session, c.value); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheckWhere(t *testing.T) {
+	db := newTestDB(t, DialectMySQL)
+	values := map[string]interface{}{"amount": 10}
+	type empty struct {
+		Status string `session:"status"`
+	}
+
+	cases := []struct {
+		name    string
+		data    interface{}
+		opts    []SessionOption
+		missing bool
+	}{
+		{"no where", values, []SessionOption{SessionCondition.WithModel(&testOrder{})}, true},
+		{"empty query struct", values, append([]SessionOption{SessionCondition.WithModel(&testOrder{})}, ParseSessionOption(&empty{})...), true},
+		{"where", values, []SessionOption{SessionCondition.WithModel(&testOrder{}), SessionCondition.WithEqual("status", "paid")}, false},
+		{"data primary key", &testOrder{ID: 1}, nil, false},
+		{"model primary key", values, []SessionOption{SessionCondition.WithModel(&testOrder{ID: 1})}, false},
+		{"zero data", &testOrder{Amount: 10}, nil, true},
+		{"allow full table", values, []SessionOption{SessionCondition.WithModel(&testOrder{}), SessionCondition.WithAllowFullTable()}, false},
+	}
+	for _, c := range cases {
+		session := db.Model(c.data)
+		for _, opt := range c.opts {
+			session = opt.Process(session)
+		}
+		err := checkWhere(session, c.data)
+		if missing := errors.Is(err, ErrMissingWhere); missing != c.missing {
+			t.Errorf("%s: got %v, want missing = %v", c.name, err, c.missing)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+)
+
+const sessionLockKey = "gorm_where:optimistic_lock"
+
+// ErrStaleObject 乐观锁更新没有命中记录，版本号已经被其他请求修改
+var ErrStaleObject = errors.New("stale object")
+
+// RetryOnConflictAttempts RetryOnConflict 最多执行的次数
+var RetryOnConflictAttempts = 3
+
+type optimisticLock struct {
+	column   string
+	expected int64
+	// rollback 更新失败时恢复 data 中的版本号
+	rollback func()
+}
+
+// WithOptimisticLock Updates 时增加 column = expected 条件，并把 column 更新为 expected + 1，
+// 不使用时读取 model 中 session:"version" 的字段
+func (*_sessionCondition) WithOptimisticLock(column string, expected int64) SessionOption {
+	return SessionOption{
+		Type: sessionOptionLock,
+		Process: func(session *gorm.DB) *gorm.DB {
+			return session.Set(sessionLockKey, &optimisticLock{column: column, expected: expected})
+		},
+	}
+}
+
+// RetryOnConflict fn 返回 ErrStaleObject 时重新执行，fn 中需要重新读取记录再修改
+func RetryOnConflict(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for i := 0; i < RetryOnConflictAttempts; i++ {
+		if err = fn(ctx); !errors.Is(err, ErrStaleObject) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// getOptimisticLock 没有 WithOptimisticLock 时，只有 model 就是更新的 data（没有 WithModel）才使用其中 session:"version" 字段的值，
+// 使用 WithModel 时（如 Patch、更新 map）model 中的版本号不一定是读取出来的值，需要显式使用 WithOptimisticLock
+func getOptimisticLock(session *gorm.DB, data interface{}) (*optimisticLock, error) {
+	if value, ok := session.Get(sessionLockKey); ok {
+		lock := *value.(*optimisticLock)
+		return &lock, nil
+	}
+
+	model := GetElem(reflect.ValueOf(session.Statement.Model))
+	if model.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	if !isSamePointer(session.Statement.Model, data) {
+		return nil, nil
+	}
+	for _, field := range getSessionPlan(model.Type()) {
+		if field.opt.name != "version" {
+			continue
+		}
+		stmt := &gorm.Statement{DB: session}
+		if err := stmt.Parse(session.Statement.Model); err != nil {
+			return nil, errors.Wrapf(err, "optimistic lock")
+		}
+		schemaField := stmt.Schema.LookUpField(field.fieldName)
+		if schemaField == nil {
+			return nil, errors.Errorf("optimistic lock: %s.%s is not a column", model.Type().Name(), field.fieldName)
+		}
+		expected, ok := loadVersion(model.Field(field.index))
+		if !ok {
+			return nil, errors.Errorf("optimistic lock: %s.%s is not an integer", model.Type().Name(), field.fieldName)
+		}
+		return &optimisticLock{column: schemaField.DBName, expected: expected}, nil
+	}
+	return nil, nil
+}
+
+// apply 增加版本号条件，并在 data 中设置新的版本号
+func (lock *optimisticLock) apply(session *gorm.DB, data interface{}) (*gorm.DB, interface{}, error) {
+	next := lock.expected + 1
+
+	switch values := data.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(values)+1)
+		for column, value := range values {
+			copied[column] = value
+		}
+		copied[lock.column] = next
+		data = copied
+	default:
+		value := reflect.ValueOf(data)
+		if value.Kind() != reflect.Ptr || GetElem(value).Kind() != reflect.Struct {
+			return nil, nil, errors.Errorf("optimistic lock: %T must be a map or a struct pointer", data)
+		}
+		stmt := &gorm.Statement{DB: session}
+		if err := stmt.Parse(data); err != nil {
+			return nil, nil, errors.Wrapf(err, "optimistic lock")
+		}
+		field := stmt.Schema.LookUpField(lock.column)
+		if field == nil {
+			return nil, nil, errors.Errorf("optimistic lock: %T has no column %s", data, lock.column)
+		}
+		ctx := session.Statement.Context
+		if err := field.Set(ctx, GetElem(value), next); err != nil {
+			return nil, nil, errors.Wrapf(err, "optimistic lock")
+		}
+		lock.rollback = func() {
+			_ = field.Set(ctx, GetElem(value), lock.expected)
+		}
+	}
+
+	if len(session.Statement.Selects) != 0 {
+		session.Statement.Selects = append(session.Statement.Selects, lock.column)
+	}
+
+	session = session.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: lock.column}, Value: lock.expected})
+	return session, data, nil
+}
+
+func (lock *optimisticLock) stale() error {
+	if lock.rollback != nil {
+		lock.rollback()
+	}
+	return errors.Wrapf(ErrStaleObject, "%s = %d", lock.column, lock.expected)
+}
+
+func loadVersion(value reflect.Value) (int64, bool) {
+	value = GetElem(value)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), true
+	}
+	return 0, false
+}
+
+func isSamePointer(a interface{}, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Ptr && vb.Kind() == reflect.Ptr && va.Pointer() == vb.Pointer()
+}
@@ -0,0 +1,49 @@
+package database
+
+import "testing"
+
+type lockOrder struct {
+	ID          int64
+	Amount      int
+	LockVersion int `session:"version"`
+}
+
+func TestGetOptimisticLock(t *testing.T) {
+	db := newTestDB(t, DialectMySQL)
+	order := &lockOrder{ID: 3, LockVersion: 5}
+	values := map[string]interface{}{"amount": 10}
+
+	cases := []struct {
+		name     string
+		data     interface{}
+		opts     []SessionOption
+		column   string
+		expected int64
+	}{
+		{"data is the model", order, nil, "lock_version", 5},
+		{"map with a loaded model", values, []SessionOption{SessionCondition.WithModel(&lockOrder{ID: 3})}, "", 0},
+		{"struct with another model", &lockOrder{Amount: 10}, []SessionOption{SessionCondition.WithModel(order)}, "", 0},
+		{"explicit lock", values, []SessionOption{SessionCondition.WithModel(&lockOrder{}), SessionCondition.WithOptimisticLock("lock_version", 7)}, "lock_version", 7},
+		{"no version field", &testOrder{ID: 3}, nil, "", 0},
+	}
+	for _, c := range cases {
+		session := db.Model(c.data)
+		for _, opt := range c.opts {
+			session = opt.Process(session)
+		}
+		lock, err := getOptimisticLock(session, c.data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if len(c.column) == 0 {
+			if lock != nil {
+				t.Errorf("%s: want no lock, got %+v", c.name, lock)
+			}
+			continue
+		}
+		if lock == nil || lock.column != c.column || lock.expected != c.expected {
+			t.Errorf("%s: got %+v, want %s = %d", c.name, lock, c.column, c.expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidPatchPath patch 的字段不存在、不允许更新或者 model 中没有对应的列
+var ErrInvalidPatchPath = errors.New("invalid patch path")
+
+// Patch 按字段掩码更新，paths 为 dto 的 json 字段名，掩码中的字段即使是零值也会更新
+func (dao *DAO) Patch(ctx context.Context, model interface{}, dto interface{}, paths []string, searchOpt ...SessionOption) (int64, error) {
+	values, err := buildPatchValues(ctx, model, dto, paths, nil)
+	if err != nil {
+		return 0, err
+	}
+	return dao.patch(ctx, model, values, searchOpt)
+}
+
+// PatchJSON 按 JSON merge patch 更新，body 中出现的顶层字段会被更新，null 更新为 NULL
+func (dao *DAO) PatchJSON(ctx context.Context, model interface{}, dto interface{}, body []byte, searchOpt ...SessionOption) (int64, error) {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return 0, errors.Wrapf(err, "PatchJSON fail")
+	}
+	if err := json.Unmarshal(body, dto); err != nil {
+		return 0, errors.Wrapf(err, "PatchJSON fail")
+	}
+
+	paths := make([]string, 0, len(raw))
+	nulls := make(map[string]bool)
+	for path, value := range raw {
+		paths = append(paths, path)
+		if string(value) == "null" {
+			nulls[path] = true
+		}
+	}
+	sort.Strings(paths)
+
+	values, err := buildPatchValues(ctx, model, dto, paths, nulls)
+	if err != nil {
+		return 0, err
+	}
+	return dao.patch(ctx, model, values, searchOpt)
+}
+
+func (dao *DAO) patch(ctx context.Context, model interface{}, values map[string]interface{}, searchOpt []SessionOption) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	columns := make([]interface{}, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	opts := append([]SessionOption{SessionCondition.WithModel(model), SessionCondition.WithUpdateCols(columns)}, searchOpt...)
+	return dao.Updates(ctx, values, opts...)
+}
+
+func buildPatchValues(ctx context.Context, model interface{}, dto interface{}, paths []string, nulls map[string]bool) (map[string]interface{}, error) {
+	elem := GetElem(reflect.ValueOf(dto))
+	if elem.Kind() != reflect.Struct {
+		return nil, errors.New("Patch fail: dto must be a struct")
+	}
+
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session = session.Model(model)
+	if err := session.Statement.Parse(model); err != nil {
+		return nil, errors.Wrapf(err, "Patch fail")
+	}
+	modelSchema := session.Statement.Schema
+
+	fields := make(map[string]sessionFieldPlan)
+	for _, field := range getSessionPlan(elem.Type()) {
+		name := strings.Split(elem.Type().Field(field.index).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.fieldName
+		}
+		fields[name] = field
+	}
+
+	values := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		field, ok := fields[path]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidPatchPath, "%s: no such field", path)
+		}
+		column, ok := field.column()
+		if !ok || field.opt.noUpdate {
+			return nil, errors.Wrapf(ErrInvalidPatchPath, "%s: not updatable", path)
+		}
+		if modelSchema.LookUpField(column) == nil {
+			return nil, errors.Wrapf(ErrInvalidPatchPath, "%s: column %s not found in %s", path, column, modelSchema.Name)
+		}
+
+		value := elem.Field(field.index)
+		if nulls[path] || value.Kind() == reflect.Ptr && value.IsNil() {
+			values[column] = nil
+			continue
+		}
+		values[column] = GetElem(value).Interface()
+	}
+	return values, nil
+}
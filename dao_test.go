@@ -0,0 +1,61 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"testing"
+)
+
+func TestCountQueryMatchScore(t *testing.T) {
+	db := newTestDB(t, DialectMySQL)
+	opts := []SessionOption{
+		SessionCondition.WithMatch([]string{"title"}, "go", MatchOptions{ScoreColumn: "score"}),
+	}
+
+	list := findSQL(db, &[]testOrder{}, opts...)
+	if !strings.Contains(list, "AS score") || !strings.Contains(list, "ORDER BY score DESC") {
+		t.Errorf("list sql must select and order by the score: %s", list)
+	}
+
+	var count int64
+	stmt := countQuery(db.Session(&gorm.Session{DryRun: true}).Model(&testOrder{}), opts).Count(&count).Statement
+	sql := stmt.SQL.String()
+	if !strings.HasPrefix(sql, "SELECT count(*) FROM") {
+		t.Errorf("count sql must only select count(*): %s", sql)
+	}
+	if strings.Contains(sql, "score") || !strings.Contains(sql, "match(title)") {
+		t.Errorf("count sql must keep the match condition without the score: %s", sql)
+	}
+}
+
+func TestCountQueryJoin(t *testing.T) {
+	db := newTestDB(t, DialectMySQL)
+	opts := []SessionOption{
+		SessionCondition.WithJoinSpec(NewJoin(JoinInner, "users").On("users.id = test_orders.user_id")),
+	}
+
+	var count int64
+	sql := countQuery(db.Session(&gorm.Session{DryRun: true}).Model(&testOrder{}), opts).Count(&count).Statement.SQL.String()
+	if !strings.Contains(sql, "INNER JOIN `users` ON users.id = test_orders.user_id") {
+		t.Errorf("count sql must keep the inner join: %s", sql)
+	}
+}
+
+func TestWithConflict(t *testing.T) {
+	rows := []testOrder{{ID: 1, Amount: 10}}
+
+	stmt := SessionCondition.WithConflict("amount").Process(newTestDB(t, DialectMySQL)).Statement
+	onConflict, ok := stmt.Clauses["ON CONFLICT"].Expression.(clause.OnConflict)
+	if stmt.Error != nil || !ok {
+		t.Fatalf("mysql: got %v %#v", stmt.Error, stmt.Clauses["ON CONFLICT"].Expression)
+	}
+	if len(onConflict.DoUpdates) != 1 || onConflict.DoUpdates[0].Column.Name != "amount" {
+		t.Errorf("mysql: unexpected updates %+v", onConflict.DoUpdates)
+	}
+
+	res := SessionCondition.WithConflict("amount").Process(newTestDB(t, DialectPostgres).Session(&gorm.Session{DryRun: true})).Create(&rows)
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "requires conflict columns") {
+		t.Errorf("postgres: got %v, want conflict columns error", res.Error)
+	}
+}
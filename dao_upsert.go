@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"sort"
+)
+
+// UpsertSpec ConflictColumns 为冲突的唯一键，MySQL 按所有唯一键判断冲突，会忽略该配置
+type UpsertSpec struct {
+	ConflictColumns   []string
+	UpdateColumns     []string
+	UpdateExpressions map[string]clause.Expr
+	DoNothing         bool
+}
+
+// UpsertResult CountsReported 为 false 时驱动没有区分插入和更新的行数，只有 RowsAffected 可信
+type UpsertResult struct {
+	RowsAffected   int64
+	Inserted       int64
+	Updated        int64
+	CountsReported bool
+}
+
+func (spec UpsertSpec) onConflict() clause.OnConflict {
+	onConflict := clause.OnConflict{DoNothing: spec.DoNothing}
+	for _, column := range spec.ConflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if spec.DoNothing {
+		return onConflict
+	}
+	onConflict.DoUpdates = clause.AssignmentColumns(spec.UpdateColumns)
+	columns := make([]string, 0, len(spec.UpdateExpressions))
+	for column := range spec.UpdateExpressions {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{Column: clause.Column{Name: column}, Value: spec.UpdateExpressions[column]})
+	}
+	return onConflict
+}
+
+func (*_sessionCondition) WithUpsert(spec UpsertSpec) SessionOption {
+	return SessionOption{
+		Type: sessionOptionUpdateCols,
+		Process: func(session *gorm.DB) *gorm.DB {
+			expr, err := getDialect(session).Upsert(spec)
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			return session.Clauses(expr)
+		},
+	}
+}
+
+// Upsert 插入，唯一键冲突时按 spec 更新或忽略，data 可以是单条记录或切片
+func (dao *DAO) Upsert(ctx context.Context, data interface{}, spec UpsertSpec, searchOpt ...SessionOption) (UpsertResult, error) {
+	session, err := Session.getFromContext(ctx)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	if !spec.DoNothing && len(spec.UpdateColumns) == 0 && len(spec.UpdateExpressions) == 0 {
+		return UpsertResult{}, errors.New("Upsert fail: no update columns, use DoNothing to ignore conflicts")
+	}
+
+	for _, opt := range searchOpt {
+		session = opt.Process(session)
+	}
+	res := SessionCondition.WithUpsert(spec).Process(session).Create(data)
+	if session.DryRun {
+		return UpsertResult{}, newDryRunError(res)
+	}
+	if res.Error != nil {
+		return UpsertResult{}, res.Error
+	}
+
+	rows := int64(1)
+	if value := GetElem(reflect.ValueOf(data)); value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		rows = int64(value.Len())
+	}
+	return getDialect(res).UpsertResult(spec, rows, res.RowsAffected), nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+	"strings"
+	"testing"
+)
+
+// testDialector 不连接数据库的 Dialector，配合 DryRun 只生成 SQL
+type testDialector struct {
+	name string
+}
+
+func (d testDialector) Name() string {
+	return d.name
+}
+
+func (d testDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	return nil
+}
+
+func (d testDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{}
+}
+
+func (d testDialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d testDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (d testDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteByte('?')
+}
+
+func (d testDialector) QuoteTo(writer clause.Writer, str string) {
+	quote := byte('`')
+	if d.name != DialectMySQL {
+		quote = '"'
+	}
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		writer.WriteByte(quote)
+		writer.WriteString(part)
+		writer.WriteByte(quote)
+	}
+}
+
+func (d testDialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, "'", vars...)
+}
+
+// newTestDB 返回 DryRun 模式的 *gorm.DB，name 决定使用的 Dialect
+func newTestDB(t testing.TB, name string) *gorm.DB {
+	db, err := gorm.Open(testDialector{name: name}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// findSQL 在 session 上依次应用 opts 后生成查询 model 的 SQL
+func findSQL(session *gorm.DB, model interface{}, opts ...SessionOption) string {
+	session = session.Session(&gorm.Session{DryRun: true})
+	for _, opt := range opts {
+		session = processSessionOption(opt, session)
+	}
+	stmt := session.Find(model).Statement
+	return stmt.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
+
+type testOrder struct {
+	ID     int64
+	UserID int64
+	Amount int
+	Status string
+}
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"strings"
+	"time"
 )
 
 type _sessionCondition struct{}
@@ -29,20 +31,23 @@ func (*_sessionCondition) WithModel(model interface{}) SessionOption {
 	}
 }
 
-func (*_sessionCondition) WithJoin(joinOperator string, table interface{}, condition string, args ...interface{}) SessionOption {
-	return SessionOption{
-		Type: sessionOptionTable,
-		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Joins(fmt.Sprintf("%s `%s` on %s ", joinOperator, table, condition))
-		},
-	}
+func (c *_sessionCondition) WithJoin(joinOperator string, table interface{}, condition string, args ...interface{}) SessionOption {
+	return c.WithJoinSpec(NewJoin(joinOperator, fmt.Sprint(table)).On(condition, args...))
 }
 
-func (*_sessionCondition) WithJoinAs(joinOperator string, table, as interface{}, condition string, args ...interface{}) SessionOption {
+func (c *_sessionCondition) WithJoinAs(joinOperator string, table, as interface{}, condition string, args ...interface{}) SessionOption {
+	return c.WithJoinSpec(NewJoin(joinOperator, fmt.Sprint(table)).As(fmt.Sprint(as)).On(condition, args...))
+}
+
+// WithJoinSpec 列表查询总是关联，count 查询只在 where 条件引用了关联表时才关联
+func (*_sessionCondition) WithJoinSpec(join *JoinSpec) SessionOption {
 	return SessionOption{
-		Type: sessionOptionTable,
-		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Joins(fmt.Sprintf("%s `%s` on %s ", joinOperator, table, condition))
+		Type:    sessionOptionJoin,
+		Process: join.apply,
+		CountProcess: func(session *gorm.DB) *gorm.DB {
+			joins, _ := session.Get(sessionCountJoinsKey)
+			list, _ := joins.([]*JoinSpec)
+			return session.Set(sessionCountJoinsKey, append(list[:len(list):len(list)], join))
 		},
 	}
 }
@@ -91,14 +96,14 @@ func (*_sessionCondition) WithGroupBy(groupBy string) SessionOption {
 	}
 }
 
-func (*_sessionCondition) WithHaving(having string) SessionOption {
+func (*_sessionCondition) WithHaving(having string, args ...interface{}) SessionOption {
 	return SessionOption{
-		Type: sessionOptionGroupBy,
+		Type: sessionOptionHaving,
 		Process: func(session *gorm.DB) *gorm.DB {
 			if len(having) == 0 {
 				return session
 			}
-			return session.Having(having)
+			return session.Having(having, args...)
 		},
 	}
 }
@@ -223,6 +228,150 @@ func (*_sessionCondition) WithBetween(field string, begin interface{}, end inter
 	}
 }
 
+func (*_sessionCondition) WithRange(field string, from interface{}, to interface{}, excludeFrom bool, excludeTo bool) SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			hasFrom, hasTo := !isZero(from), !isZero(to)
+			if hasFrom && hasTo && !excludeFrom && !excludeTo {
+				return session.Where(fmt.Sprintf("%s between ? and ?", field), from, to)
+			}
+			if hasFrom {
+				if excludeFrom {
+					session = session.Where(fmt.Sprintf("%s > ?", field), from)
+				} else {
+					session = session.Where(fmt.Sprintf("%s >= ?", field), from)
+				}
+			}
+			if hasTo {
+				if excludeTo {
+					session = session.Where(fmt.Sprintf("%s < ?", field), to)
+				} else {
+					session = session.Where(fmt.Sprintf("%s <= ?", field), to)
+				}
+			}
+			return session
+		},
+	}
+}
+
+// WithDate 按天查询，day 所在时区的 [当天 0 点, 次日 0 点)
+func (c *_sessionCondition) WithDate(field string, day time.Time) SessionOption {
+	begin := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return c.WithRange(field, begin, begin.AddDate(0, 0, 1), false, true)
+}
+
+func (*_sessionCondition) WithPreload(assoc string, opts ...SessionOption) SessionOption {
+	return SessionOption{
+		Type: sessionOptionPreload,
+		Process: func(session *gorm.DB) *gorm.DB {
+			if len(opts) == 0 {
+				return session.Preload(assoc)
+			}
+			return session.Preload(assoc, func(preload *gorm.DB) *gorm.DB {
+				for _, opt := range opts {
+					preload = processSessionOption(opt, preload)
+				}
+				return preload
+			})
+		},
+	}
+}
+
+// WithJoinsPreload belongs-to/has-one 关联通过 left join 一次查出
+func (*_sessionCondition) WithJoinsPreload(assoc string, opts ...SessionOption) SessionOption {
+	return SessionOption{
+		Type: sessionOptionPreload,
+		Process: func(session *gorm.DB) *gorm.DB {
+			if len(opts) == 0 {
+				return session.Joins(assoc)
+			}
+			cond := session.Session(&gorm.Session{NewDB: true})
+			for _, opt := range opts {
+				cond = processSessionOption(opt, cond)
+			}
+			return session.Joins(assoc, cond)
+		},
+	}
+}
+
+// WithInSubquery field in (select ... from table where opts)，子查询的列通过 WithSelect 指定，默认 id
+func (*_sessionCondition) WithInSubquery(field string, table string, opts ...SessionOption) SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			inner, err := buildSubquery(session, table, opts)
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			if len(inner.Statement.Selects) == 0 {
+				inner = inner.Select("id")
+			}
+			return session.Where(fmt.Sprintf("%s in (?)", field), inner)
+		},
+	}
+}
+
+// WithExists table 为 RegisterJoin 注册的名字时使用其 on 条件关联外层查询，也可以在 opts 中通过 WithWhere 关联
+func (*_sessionCondition) WithExists(table string, opts ...SessionOption) SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			inner, err := buildSubquery(session, table, opts)
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			return session.Where("exists (?)", inner.Select("1"))
+		},
+	}
+}
+
+func (*_sessionCondition) WithNotExists(table string, opts ...SessionOption) SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			inner, err := buildSubquery(session, table, opts)
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			return session.Where("not exists (?)", inner.Select("1"))
+		},
+	}
+}
+
+// WithTrashed 查询时包含已软删除的数据
+func (*_sessionCondition) WithTrashed() SessionOption {
+	return SessionOption{
+		Type: sessionOptionScope,
+		Process: func(session *gorm.DB) *gorm.DB {
+			return session.Unscoped()
+		},
+	}
+}
+
+// OnlyTrashed 只查询已软删除的数据
+func (*_sessionCondition) OnlyTrashed() SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			return session.Unscoped().Where(trashedCondition{})
+		},
+	}
+}
+
+// WithUnscoped 忽略软删除，用于 Delete 时会物理删除
+func (*_sessionCondition) WithUnscoped() SessionOption {
+	return SessionOption{
+		Type: sessionOptionScope,
+		Process: func(session *gorm.DB) *gorm.DB {
+			return session.Unscoped()
+		},
+	}
+}
+
 func (*_sessionCondition) WithNoCount() SessionOption {
 	return SessionOption{
 		Type: sessionOptionNoCount,
@@ -250,6 +399,44 @@ func (*_sessionCondition) WithOr(condition string, param ...interface{}) Session
 	}
 }
 
+func (*_sessionCondition) WithJSONEq(field string, path string, value interface{}) SessionOption {
+	return withDialectCondition(func(dialect Dialect) (string, []interface{}, error) {
+		return dialect.JSONEq(field, path, value)
+	})
+}
+
+func (*_sessionCondition) WithJSONHasKey(field string, path string) SessionOption {
+	return withDialectCondition(func(dialect Dialect) (string, []interface{}, error) {
+		return dialect.JSONHasKey(field, path)
+	})
+}
+
+func (*_sessionCondition) WithJSONArrayContains(field string, path string, value interface{}) SessionOption {
+	return withDialectCondition(func(dialect Dialect) (string, []interface{}, error) {
+		return dialect.JSONArrayContains(field, path, value)
+	})
+}
+
+func (*_sessionCondition) WithJSONLengthGt(field string, path string, length interface{}) SessionOption {
+	return withDialectCondition(func(dialect Dialect) (string, []interface{}, error) {
+		return dialect.JSONLengthGt(field, path, length)
+	})
+}
+
+func withDialectCondition(build func(dialect Dialect) (string, []interface{}, error)) SessionOption {
+	return SessionOption{
+		Type: sessionOptionOther,
+		Process: func(session *gorm.DB) *gorm.DB {
+			condition, args, err := build(getDialect(session))
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			return session.Where(condition, args...)
+		},
+	}
+}
+
 func (*_sessionCondition) WithDistinct(condition string) SessionOption {
 	return SessionOption{
 		Type: sessionOptionSelect,
@@ -279,10 +466,14 @@ func (*_sessionCondition) WithSelect(field interface{}, fields ...interface{}) S
 }
 
 func (*_sessionCondition) WithUpdateCols(cols []interface{}) SessionOption {
+	columns := make([]string, 0, len(cols))
+	for _, col := range cols {
+		columns = append(columns, fmt.Sprint(col))
+	}
 	return SessionOption{
 		Type: sessionOptionUpdateCols,
 		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Select(cols)
+			return session.Select(columns)
 		},
 	}
 }
@@ -291,31 +482,95 @@ func (*_sessionCondition) ParseSessionOptions(source interface{}) []SessionOptio
 	return ParseSessionOption(source)
 }
 
-func (*_sessionCondition) WithConflict(fields ...string) SessionOption {
-	return SessionOption{
-		Type: sessionOptionUpdateCols,
-		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Clauses(clause.OnConflict{
-				DoUpdates: clause.AssignmentColumns(fields),
-			})
-		},
-	}
+func (*_sessionCondition) ParseSessionOptionsContext(ctx context.Context, source interface{}) []SessionOption {
+	return ParseSessionOptionContext(ctx, source)
+}
+
+// WithConflict 冲突时更新 fields，没有指定冲突列，只有 MySQL（ON DUPLICATE KEY UPDATE）可用，其它数据库返回错误
+//
+// Deprecated: 使用 WithUpsert 并指定 ConflictColumns
+func (c *_sessionCondition) WithConflict(fields ...string) SessionOption {
+	return c.WithUpsert(UpsertSpec{UpdateColumns: fields})
 }
 
 func (*_sessionCondition) WithIgnore() SessionOption {
 	return SessionOption{
 		Type: sessionOptionUpdateCols,
 		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Clauses(clause.Insert{Modifier: "IGNORE"})
+			expr, err := getDialect(session).InsertIgnore()
+			if err != nil {
+				session.AddError(err)
+				return session
+			}
+			return session.Clauses(expr)
 		},
 	}
 }
 
-func (*_sessionCondition) WithMatch(fields []string, value interface{}) SessionOption {
-	return SessionOption{
-		Type: sessionOptionMatch,
-		Process: func(session *gorm.DB) *gorm.DB {
-			return session.Where(fmt.Sprintf("match(%s) against('%s' in NATURAL LANGUAGE MODE)", strings.Join(fields, ","), value))
-		},
+const (
+	MatchModeNatural = "natural"
+	MatchModeBoolean = "boolean"
+)
+
+// MatchOptions 全文检索配置，ScoreColumn 不为空时把相关度作为该列查出并按相关度倒序
+type MatchOptions struct {
+	Mode           string
+	QueryExpansion bool
+	MinScore       float64
+	ScoreColumn    string
+}
+
+func (*_sessionCondition) WithMatch(fields []string, value interface{}, opts ...MatchOptions) SessionOption {
+	opt := MatchOptions{}
+	if len(opts) != 0 {
+		opt = opts[0]
 	}
+
+	where := func(session *gorm.DB) *gorm.DB {
+		condition, args, err := getDialect(session).Match(fields, value, opt)
+		if err != nil {
+			session.AddError(err)
+			return session
+		}
+		return session.Where(condition, args...)
+	}
+
+	option := SessionOption{
+		Type:    sessionOptionMatch,
+		Process: where,
+	}
+	if len(opt.ScoreColumn) == 0 {
+		return option
+	}
+
+	option.CountProcess = where
+	option.Process = func(session *gorm.DB) *gorm.DB {
+		score, args, err := getDialect(session).MatchScore(fields, value, opt)
+		if err != nil {
+			session.AddError(err)
+			return session
+		}
+		session = where(session).Clauses(matchScoreSelect{SQL: fmt.Sprintf(", %s AS %s", score, opt.ScoreColumn), Vars: args})
+		return session.Order(clause.OrderByColumn{Column: clause.Column{Name: opt.ScoreColumn, Raw: true}, Desc: true})
+	}
+	return option
+}
+
+// matchScoreSelect 生成 sql 时追加在 select 列之后，不受之前或之后 WithSelect 的影响
+type matchScoreSelect clause.Expr
+
+func (s matchScoreSelect) Build(builder clause.Builder) {
+	clause.Expr(s).Build(builder)
+}
+
+func (s matchScoreSelect) ModifyStatement(stmt *gorm.Statement) {
+	selectClause := stmt.Clauses["SELECT"]
+	selectClause.AfterExpression = clause.Expr(s)
+	stmt.Clauses["SELECT"] = selectClause
+}
+
+func (*_sessionCondition) WithJSONContains(field string, value interface{}) SessionOption {
+	return withDialectCondition(func(dialect Dialect) (string, []interface{}, error) {
+		return dialect.JSONContains(field, value)
+	})
 }
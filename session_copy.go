@@ -0,0 +1,351 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/pkg/errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// CopyTo 按 session tag 名（没有 tag 时为 CamelToSnake(字段名)）在 DTO 和 model 之间复制字段，
+// 忽略 ignore-copy 的字段，匿名 struct 字段（如 gorm.Model）展开后按其中的字段复制，
+// 支持指针、sql.Null*、time.Time 与字符串（按 layout/tz）之间的转换，
+// 返回 src 中没有对应字段或者类型无法转换的字段名
+func CopyTo(dst interface{}, src interface{}) ([]string, error) {
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Ptr || dstValue.IsNil() {
+		return nil, errors.New("CopyTo fail: dst must be a non-nil pointer")
+	}
+	dstElem := GetElem(dstValue)
+	srcElem := GetElem(reflect.ValueOf(src))
+	if dstElem.Kind() != reflect.Struct || srcElem.Kind() != reflect.Struct {
+		return nil, errors.New("CopyTo fail: dst and src must be structs")
+	}
+
+	dstFields := make(map[string]copyField)
+	for _, field := range getCopyFields(dstElem.Type()) {
+		dstFields[field.key] = field
+	}
+
+	unmapped := make([]string, 0)
+	for _, field := range getCopyFields(srcElem.Type()) {
+		if field.opt.ignoreCopy {
+			continue
+		}
+		target, ok := dstFields[field.key]
+		if !ok {
+			unmapped = append(unmapped, field.fieldName)
+			continue
+		}
+		if target.opt.ignoreCopy {
+			continue
+		}
+
+		source := fieldByPath(srcElem, field.path, false)
+		if !source.IsValid() {
+			continue
+		}
+
+		opt := target.opt
+		if len(opt.layout) == 0 {
+			opt.layout = field.opt.layout
+		}
+		if opt.location == nil {
+			opt.location = field.opt.location
+		}
+
+		value, ok := copyValue(source, target.typ, opt)
+		if !ok {
+			unmapped = append(unmapped, field.fieldName)
+			continue
+		}
+		if out := fieldByPath(dstElem, target.path, true); out.IsValid() {
+			out.Set(value)
+		}
+	}
+	return unmapped, nil
+}
+
+// copyField CopyTo 复制的字段，path 为从最外层 struct 开始的字段下标
+type copyField struct {
+	sessionFieldPlan
+	key  string
+	path []int
+}
+
+var copyFieldsCache sync.Map
+
+// getCopyFields 和 gorm 的 schema 一样展开匿名 struct 字段（如 gorm.Model），外层的同名字段优先
+func getCopyFields(typ reflect.Type) []copyField {
+	if fields, ok := copyFieldsCache.Load(typ); ok {
+		return fields.([]copyField)
+	}
+
+	type embedded struct {
+		typ  reflect.Type
+		path []int
+	}
+	fields := make([]copyField, 0, typ.NumField())
+	seen := make(map[string]bool)
+	visited := map[reflect.Type]bool{typ: true}
+	for level := []embedded{{typ: typ}}; len(level) != 0; {
+		next := make([]embedded, 0)
+		for _, current := range level {
+			for _, field := range getSessionPlan(current.typ) {
+				path := append(current.path[:len(current.path):len(current.path)], field.index)
+				if inner, ok := embeddedStruct(field); ok {
+					if !visited[inner] && !field.opt.ignoreCopy {
+						visited[inner] = true
+						next = append(next, embedded{typ: inner, path: path})
+					}
+					continue
+				}
+				column, ok := field.column()
+				if !ok || seen[copyKey(column)] {
+					continue
+				}
+				seen[copyKey(column)] = true
+				fields = append(fields, copyField{sessionFieldPlan: field, key: copyKey(column), path: path})
+			}
+		}
+		level = next
+	}
+
+	actual, _ := copyFieldsCache.LoadOrStore(typ, fields)
+	return actual.([]copyField)
+}
+
+// embeddedStruct 没有 session 列名的匿名 struct（或 struct 指针）字段需要展开
+func embeddedStruct(field sessionFieldPlan) (reflect.Type, bool) {
+	if !field.anonymous || len(field.opt.name) != 0 {
+		return nil, false
+	}
+	typ := field.typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return nil, false
+	}
+	return typ, true
+}
+
+// fieldByPath 按 path 取字段，匿名 struct 指针为 nil 时 alloc 为 true 则分配，否则返回无效的 Value
+func fieldByPath(elem reflect.Value, path []int, alloc bool) reflect.Value {
+	for i, index := range path {
+		if i != 0 && elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				if !alloc || !elem.CanSet() {
+					return reflect.Value{}
+				}
+				elem.Set(reflect.New(elem.Type().Elem()))
+			}
+			elem = elem.Elem()
+		}
+		elem = elem.Field(index)
+	}
+	return elem
+}
+
+// copyKey 忽略大小写和下划线，CamelToSnake("UserID") 为 user_i_d，需要和 user_id 匹配
+func copyKey(column string) string {
+	return strings.ToLower(strings.ReplaceAll(column, "_", ""))
+}
+
+// copyValue 把 value 转换为 typ，nil 转换为 typ 的零值
+func copyValue(value reflect.Value, typ reflect.Type, opt sessionOptionTag) (reflect.Value, bool) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Zero(typ), true
+		}
+		value = value.Elem()
+	}
+
+	if value.Type().AssignableTo(typ) {
+		return value, true
+	}
+
+	if value.Type().Implements(valuerType) && value.Type() != timeType {
+		v, err := value.Interface().(driver.Valuer).Value()
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		if v == nil {
+			return reflect.Zero(typ), true
+		}
+		value = reflect.ValueOf(v)
+		if value.Type().AssignableTo(typ) {
+			return value, true
+		}
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		elem, ok := copyValue(value, typ.Elem(), opt)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, true
+	}
+
+	if reflect.PtrTo(typ).Implements(scannerType) && typ != timeType {
+		ptr := reflect.New(typ)
+		if err := ptr.Interface().(sql.Scanner).Scan(value.Interface()); err != nil {
+			return reflect.Value{}, false
+		}
+		return ptr.Elem(), true
+	}
+
+	if t, ok := value.Interface().(time.Time); ok && typ.Kind() == reflect.String {
+		if t.IsZero() {
+			return reflect.Zero(typ), true
+		}
+		layout := opt.layout
+		if len(layout) == 0 {
+			layout = DefaultTimeLayout
+		}
+		return reflect.ValueOf(t.In(opt.getLocation()).Format(layout)).Convert(typ), true
+	}
+
+	if value.Kind() == reflect.String && typ == timeType {
+		if len(value.String()) == 0 {
+			return reflect.Zero(typ), true
+		}
+		layout := opt.layout
+		if len(layout) == 0 {
+			layout = DefaultTimeLayout
+		}
+		t, err := time.ParseInLocation(layout, value.String(), opt.getLocation())
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(t), true
+	}
+
+	if isNumberKind(value.Kind()) && isNumberKind(typ.Kind()) {
+		return convertNumber(value, typ)
+	}
+
+	if value.Kind() == typ.Kind() && value.Type().ConvertibleTo(typ) {
+		return value.Convert(typ), true
+	}
+
+	return reflect.Value{}, false
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertNumber 数字之间的转换，溢出、负数转无符号、小数转整数、整数超出浮点数精度等会丢失数据的情况返回 false
+func convertNumber(value reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	result := reflect.New(typ).Elem()
+
+	switch {
+	case isIntKind(typ.Kind()):
+		var v int64
+		switch {
+		case isIntKind(value.Kind()):
+			v = value.Int()
+		case isUintKind(value.Kind()):
+			if value.Uint() > math.MaxInt64 {
+				return reflect.Value{}, false
+			}
+			v = int64(value.Uint())
+		default:
+			f := value.Float()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return reflect.Value{}, false
+			}
+			v = int64(f)
+		}
+		if result.OverflowInt(v) {
+			return reflect.Value{}, false
+		}
+		result.SetInt(v)
+	case isUintKind(typ.Kind()):
+		var v uint64
+		switch {
+		case isIntKind(value.Kind()):
+			if value.Int() < 0 {
+				return reflect.Value{}, false
+			}
+			v = uint64(value.Int())
+		case isUintKind(value.Kind()):
+			v = value.Uint()
+		default:
+			f := value.Float()
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+				return reflect.Value{}, false
+			}
+			v = uint64(f)
+		}
+		if result.OverflowUint(v) {
+			return reflect.Value{}, false
+		}
+		result.SetUint(v)
+	default:
+		var v float64
+		switch {
+		case isIntKind(value.Kind()):
+			v = float64(value.Int())
+			if v >= math.MaxInt64 || int64(v) != value.Int() {
+				return reflect.Value{}, false
+			}
+		case isUintKind(value.Kind()):
+			v = float64(value.Uint())
+			if v >= math.MaxUint64 || uint64(v) != value.Uint() {
+				return reflect.Value{}, false
+			}
+		default:
+			v = value.Float()
+		}
+		if result.OverflowFloat(v) {
+			return reflect.Value{}, false
+		}
+		// 整数转 float32 时同样不能超出精度
+		if typ.Kind() == reflect.Float32 && !isFloatKind(value.Kind()) && float64(float32(v)) != v {
+			return reflect.Value{}, false
+		}
+		result.SetFloat(v)
+	}
+	return result, true
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
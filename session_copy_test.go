@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"gorm.io/gorm"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestConvertNumber(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		typ   reflect.Type
+		want  interface{}
+		ok    bool
+	}{
+		{int64(127), reflect.TypeOf(int8(0)), int8(127), true},
+		{int64(128), reflect.TypeOf(int8(0)), nil, false},
+		{int64(-1), reflect.TypeOf(uint(0)), nil, false},
+		{int64(42), reflect.TypeOf(uint16(0)), uint16(42), true},
+		{uint64(math.MaxUint64), reflect.TypeOf(int64(0)), nil, false},
+		{uint64(300), reflect.TypeOf(uint8(0)), nil, false},
+		{3.0, reflect.TypeOf(0), 3, true},
+		{3.5, reflect.TypeOf(0), nil, false},
+		{-1.0, reflect.TypeOf(uint(0)), nil, false},
+		{7, reflect.TypeOf(float64(0)), float64(7), true},
+		{int64(1) << 53, reflect.TypeOf(float64(0)), float64(1 << 53), true},
+		{int64(1)<<53 + 1, reflect.TypeOf(float64(0)), nil, false},
+		{math.MaxFloat64, reflect.TypeOf(float32(0)), nil, false},
+		{0.5, reflect.TypeOf(float32(0)), float32(0.5), true},
+		{1<<24 + 1, reflect.TypeOf(float32(0)), nil, false},
+		{uint64(math.MaxUint64), reflect.TypeOf(float64(0)), nil, false},
+	}
+	for _, c := range cases {
+		got, ok := convertNumber(reflect.ValueOf(c.value), c.typ)
+		if ok != c.ok {
+			t.Errorf("%T(%v) to %s: ok = %v, want %v", c.value, c.value, c.typ, ok, c.ok)
+			continue
+		}
+		if ok && got.Interface() != c.want {
+			t.Errorf("%T(%v) to %s: got %v, want %v", c.value, c.value, c.typ, got.Interface(), c.want)
+		}
+	}
+}
+
+func TestCopyValue(t *testing.T) {
+	n := 5
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name  string
+		value interface{}
+		typ   reflect.Type
+		opt   sessionOptionTag
+		want  interface{}
+		ok    bool
+	}{
+		{"same type", "a", reflect.TypeOf(""), sessionOptionTag{}, "a", true},
+		{"pointer to value", &n, reflect.TypeOf(0), sessionOptionTag{}, 5, true},
+		{"nil pointer", (*int)(nil), reflect.TypeOf(0), sessionOptionTag{}, 0, true},
+		{"value to pointer", 5, reflect.TypeOf((*int64)(nil)), sessionOptionTag{}, int64(5), true},
+		{"null string", sql.NullString{String: "a", Valid: true}, reflect.TypeOf(""), sessionOptionTag{}, "a", true},
+		{"invalid null string", sql.NullString{}, reflect.TypeOf(""), sessionOptionTag{}, "", true},
+		{"null int64 to int", sql.NullInt64{Int64: 5, Valid: true}, reflect.TypeOf(0), sessionOptionTag{}, 5, true},
+		{"null int64 overflow", sql.NullInt64{Int64: 1 << 40, Valid: true}, reflect.TypeOf(int32(0)), sessionOptionTag{}, nil, false},
+		{"null time to time", sql.NullTime{Time: at, Valid: true}, reflect.TypeOf(time.Time{}), sessionOptionTag{}, at, true},
+		{"int to null int64", 5, reflect.TypeOf(sql.NullInt64{}), sessionOptionTag{}, sql.NullInt64{Int64: 5, Valid: true}, true},
+		{"string to null string", "a", reflect.TypeOf(sql.NullString{}), sessionOptionTag{}, sql.NullString{String: "a", Valid: true}, true},
+		{"null string to null string", sql.NullString{String: "a", Valid: true}, reflect.TypeOf(sql.NullString{}), sessionOptionTag{}, sql.NullString{String: "a", Valid: true}, true},
+		{"deleted at to null time", gorm.DeletedAt{Time: at, Valid: true}, reflect.TypeOf(sql.NullTime{}), sessionOptionTag{}, sql.NullTime{Time: at, Valid: true}, true},
+		{"time to string", at, reflect.TypeOf(""), sessionOptionTag{location: shanghai}, "2026-01-02 11:04:05", true},
+		{"string to time", "2026-01-02", reflect.TypeOf(time.Time{}), sessionOptionTag{layout: DefaultDateLayout, location: time.UTC}, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"bad time string", "x", reflect.TypeOf(time.Time{}), sessionOptionTag{}, nil, false},
+		{"int to string", 65, reflect.TypeOf(""), sessionOptionTag{}, nil, false},
+		{"string to int", "5", reflect.TypeOf(0), sessionOptionTag{}, nil, false},
+	}
+	for _, c := range cases {
+		got, ok := copyValue(reflect.ValueOf(c.value), c.typ, c.opt)
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		value := got.Interface()
+		if got.Kind() == reflect.Ptr {
+			value = got.Elem().Interface()
+		}
+		if !reflect.DeepEqual(value, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.name, value, c.want)
+		}
+	}
+}
+
+type copyUser struct {
+	gorm.Model
+	Name   string
+	Mobile sql.NullString
+	Score  int64
+}
+
+type copyBase struct {
+	ID        uint   `session:"id"`
+	CreatedAt string `session:"created_at,layout:2006-01-02,tz:UTC"`
+}
+
+type copyAudit struct {
+	Editor string
+}
+
+type copyUserDTO struct {
+	copyBase
+	*copyAudit
+	Name   string `session:"name"`
+	Mobile *string
+	Score  int8
+}
+
+func TestCopyToEmbedded(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := copyUser{
+		Model:  gorm.Model{ID: 3, CreatedAt: created},
+		Name:   "tom",
+		Mobile: sql.NullString{String: "138", Valid: true},
+		Score:  1000,
+	}
+
+	var dto copyUserDTO
+	unmapped, err := CopyTo(&dto, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(unmapped)
+	if want := []string{"DeletedAt", "Score", "UpdatedAt"}; !reflect.DeepEqual(unmapped, want) {
+		t.Errorf("unmapped = %v, want %v", unmapped, want)
+	}
+	if dto.ID != 3 || dto.CreatedAt != "2026-01-02" || dto.Name != "tom" || dto.Mobile == nil || *dto.Mobile != "138" {
+		t.Errorf("unexpected dto %+v", dto)
+	}
+	if dto.copyAudit != nil {
+		t.Errorf("embedded pointer without copied fields must stay nil")
+	}
+
+	dto.Score = 7
+	dto.copyAudit = &copyAudit{Editor: "jerry"}
+	var back copyUser
+	unmapped, err = CopyTo(&back, dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Editor"}; !reflect.DeepEqual(unmapped, want) {
+		t.Errorf("unmapped = %v, want %v", unmapped, want)
+	}
+	if back.ID != 3 || !back.CreatedAt.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)) || back.Score != 7 || back.Mobile.String != "138" {
+		t.Errorf("unexpected model %+v", back)
+	}
+}
+
+func TestCopyToShadowedField(t *testing.T) {
+	type inner struct {
+		Name string
+	}
+	type outer struct {
+		inner
+		Name string
+	}
+	var dst outer
+	if _, err := CopyTo(&dst, struct{ Name string }{"tom"}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "tom" || dst.inner.Name != "" {
+		t.Errorf("outer field must win: %+v", dst)
+	}
+}
@@ -0,0 +1,474 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"sync"
+)
+
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// ErrDialectNotSupported 当前数据库不支持的功能
+var ErrDialectNotSupported = errors.New("not supported by dialect")
+
+// Dialect 屏蔽不同数据库之间的 sql 差异，按 gorm dialector 的 Name() 选择
+type Dialect interface {
+	Name() string
+	Quote(name string) string
+	Match(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error)
+	MatchScore(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error)
+	JSONContains(column string, value interface{}) (string, []interface{}, error)
+	JSONEq(column, path string, value interface{}) (string, []interface{}, error)
+	JSONHasKey(column, path string) (string, []interface{}, error)
+	JSONArrayContains(column, path string, value interface{}) (string, []interface{}, error)
+	JSONLengthGt(column, path string, length interface{}) (string, []interface{}, error)
+	InsertIgnore() (clause.Expression, error)
+	Upsert(spec UpsertSpec) (clause.Expression, error)
+	UpsertResult(spec UpsertSpec, rows int64, affected int64) UpsertResult
+	IsDuplicateError(err error) bool
+	MaxPlaceholders() int
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{
+		DialectMySQL:    mysqlDialect{},
+		DialectPostgres: postgresDialect{},
+		DialectSQLite:   sqliteDialect{},
+		"sqlite3":       sqliteDialect{},
+	}
+)
+
+// RegisterDialect 注册或覆盖 gorm dialector 对应的 Dialect
+func RegisterDialect(name string, dialect Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[name] = dialect
+}
+
+// getDialect 未注册的 dialector 返回 unsupportedDialect，需要方言的功能都会返回 ErrDialectNotSupported
+func getDialect(session *gorm.DB) Dialect {
+	if session == nil || session.Dialector == nil {
+		return mysqlDialect{}
+	}
+
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if dialect, ok := dialects[session.Dialector.Name()]; ok {
+		return dialect
+	}
+	return unsupportedDialect{dialector: session.Dialector}
+}
+
+func unsupported(dialect Dialect, feature string) error {
+	return errors.Wrapf(ErrDialectNotSupported, "%s: %s", feature, dialect.Name())
+}
+
+func quoteWith(name string, quote string) string {
+	if strings.ContainsAny(name, quote+" ()") {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = quote + part + quote
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return DialectMySQL
+}
+
+func (mysqlDialect) Quote(name string) string {
+	return quoteWith(name, "`")
+}
+
+func (d mysqlDialect) Match(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	score, args, err := d.MatchScore(columns, value, opt)
+	if err != nil {
+		return "", nil, err
+	}
+	if opt.MinScore > 0 {
+		return score + " > ?", append(args, opt.MinScore), nil
+	}
+	return score, args, nil
+}
+
+func (mysqlDialect) MatchScore(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	modifier := "in NATURAL LANGUAGE MODE"
+	switch opt.Mode {
+	case MatchModeNatural, "":
+		if opt.QueryExpansion {
+			modifier = "in NATURAL LANGUAGE MODE WITH QUERY EXPANSION"
+		}
+	case MatchModeBoolean:
+		if opt.QueryExpansion {
+			return "", nil, errors.New("match: query expansion can not be used in boolean mode")
+		}
+		modifier = "in BOOLEAN MODE"
+	default:
+		return "", nil, errors.Errorf("match: unknown mode %s", opt.Mode)
+	}
+	return fmt.Sprintf("match(%s) against(? %s)", strings.Join(columns, ","), modifier), []interface{}{value}, nil
+}
+
+func (mysqlDialect) JSONContains(column string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("JSON_CONTAINS(%s, ?)", column), []interface{}{value}, nil
+}
+
+func (mysqlDialect) JSONEq(column, path string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, ?)) = ?", column), []interface{}{path, value}, nil
+}
+
+func (mysqlDialect) JSONHasKey(column, path string) (string, []interface{}, error) {
+	return fmt.Sprintf("JSON_CONTAINS_PATH(%s, 'one', ?)", column), []interface{}{path}, nil
+}
+
+func (mysqlDialect) JSONArrayContains(column, path string, value interface{}) (string, []interface{}, error) {
+	candidate, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "json_array_contains")
+	}
+	return fmt.Sprintf("JSON_CONTAINS(%s, ?, ?)", column), []interface{}{string(candidate), path}, nil
+}
+
+func (mysqlDialect) JSONLengthGt(column, path string, length interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("JSON_LENGTH(%s, ?) > ?", column), []interface{}{path, length}, nil
+}
+
+func (mysqlDialect) InsertIgnore() (clause.Expression, error) {
+	return clause.Insert{Modifier: "IGNORE"}, nil
+}
+
+func (mysqlDialect) Upsert(spec UpsertSpec) (clause.Expression, error) {
+	return spec.onConflict(), nil
+}
+
+// UpsertResult MySQL 插入计 1 行、更新计 2 行、值没有变化计 0 行，多行时一般无法区分
+// （1 行插入 1 行更新 1 行未变化与 3 行插入相同），只有 DoNothing、单行、全部更新或全部未变化时能准确得出
+func (mysqlDialect) UpsertResult(spec UpsertSpec, rows int64, affected int64) UpsertResult {
+	result := UpsertResult{RowsAffected: affected}
+	switch {
+	case spec.DoNothing:
+		result.Inserted, result.CountsReported = affected, true
+	case affected == 0:
+		result.CountsReported = true
+	case rows == 1 && affected == 1:
+		result.Inserted, result.CountsReported = 1, true
+	case affected == 2*rows:
+		result.Updated, result.CountsReported = rows, true
+	}
+	return result
+}
+
+func (mysqlDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+func (mysqlDialect) IsDuplicateError(err error) bool {
+	return strings.Contains(err.Error(), DbRecordExistsError)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return DialectPostgres
+}
+
+func (postgresDialect) Quote(name string) string {
+	return quoteWith(name, `"`)
+}
+
+func (d postgresDialect) Match(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	query, err := d.tsquery(opt)
+	if err != nil {
+		return "", nil, err
+	}
+	condition := fmt.Sprintf("to_tsvector(%s) @@ %s(?)", tsvectorDocument(columns), query)
+	args := []interface{}{value}
+	if opt.MinScore > 0 {
+		score, scoreArgs, _ := d.MatchScore(columns, value, opt)
+		condition = fmt.Sprintf("%s AND %s > ?", condition, score)
+		args = append(append(args, scoreArgs...), opt.MinScore)
+	}
+	return condition, args, nil
+}
+
+func (d postgresDialect) MatchScore(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	query, err := d.tsquery(opt)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("ts_rank(to_tsvector(%s), %s(?))", tsvectorDocument(columns), query), []interface{}{value}, nil
+}
+
+// tsquery boolean 模式使用 websearch_to_tsquery 支持 "", -, or 语法
+func (d postgresDialect) tsquery(opt MatchOptions) (string, error) {
+	if opt.QueryExpansion {
+		return "", unsupported(d, "match query expansion")
+	}
+	switch opt.Mode {
+	case MatchModeNatural, "":
+		return "plainto_tsquery", nil
+	case MatchModeBoolean:
+		return "websearch_to_tsquery", nil
+	}
+	return "", errors.Errorf("match: unknown mode %s", opt.Mode)
+}
+
+func (postgresDialect) JSONContains(column string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("%s @> CAST(? AS jsonb)", column), []interface{}{value}, nil
+}
+
+func (postgresDialect) JSONEq(column, path string, value interface{}) (string, []interface{}, error) {
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	expr, args := jsonbExtractPath("jsonb_extract_path_text", column, keys)
+	return expr + " = ?", append(args, fmt.Sprint(value)), nil
+}
+
+func (postgresDialect) JSONHasKey(column, path string) (string, []interface{}, error) {
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(keys) == 0 {
+		return "", nil, errors.Errorf("json_has_key: path %s has no key", path)
+	}
+	expr, args := jsonbExtractPath("jsonb_extract_path", column, keys[:len(keys)-1])
+	return fmt.Sprintf("jsonb_exists(%s, ?)", expr), append(args, keys[len(keys)-1]), nil
+}
+
+func (postgresDialect) JSONArrayContains(column, path string, value interface{}) (string, []interface{}, error) {
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	candidate, err := json.Marshal([]interface{}{value})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "json_array_contains")
+	}
+	expr, args := jsonbExtractPath("jsonb_extract_path", column, keys)
+	return expr + " @> CAST(? AS jsonb)", append(args, string(candidate)), nil
+}
+
+func (postgresDialect) JSONLengthGt(column, path string, length interface{}) (string, []interface{}, error) {
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	expr, args := jsonbExtractPath("jsonb_extract_path", column, keys)
+	return fmt.Sprintf("jsonb_array_length(%s) > ?", expr), append(args, length), nil
+}
+
+func (postgresDialect) InsertIgnore() (clause.Expression, error) {
+	return clause.OnConflict{DoNothing: true}, nil
+}
+
+func (d postgresDialect) Upsert(spec UpsertSpec) (clause.Expression, error) {
+	return conflictTargetUpsert(d, spec)
+}
+
+func (postgresDialect) UpsertResult(spec UpsertSpec, rows int64, affected int64) UpsertResult {
+	return excludedUpsertResult(spec, affected)
+}
+
+func (postgresDialect) MaxPlaceholders() int {
+	return 65535
+}
+
+func (postgresDialect) IsDuplicateError(err error) bool {
+	return strings.Contains(err.Error(), "SQLSTATE 23505") || strings.Contains(err.Error(), "duplicate key value")
+}
+
+// jsonbExtractPath 生成 jsonb_extract_path(column, ?, ?)，key 全部通过参数绑定
+func jsonbExtractPath(fn string, column string, keys []string) (string, []interface{}) {
+	if len(keys) == 0 {
+		if fn == "jsonb_extract_path_text" {
+			return fmt.Sprintf("%s::text", column), nil
+		}
+		return fmt.Sprintf("%s::jsonb", column), nil
+	}
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	return fmt.Sprintf("%s(%s::jsonb, %s)", fn, column, strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")), args
+}
+
+// parseJSONPath 把 $.a.b[0] 拆成 [a b 0]
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.Errorf("invalid json path %s", path)
+	}
+	keys := make([]string, 0)
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(path, "$"), func(r rune) bool {
+		return r == '.' || r == '[' || r == ']'
+	}) {
+		keys = append(keys, strings.Trim(part, `"`))
+	}
+	return keys, nil
+}
+
+func tsvectorDocument(columns []string) string {
+	document := make([]string, 0, len(columns))
+	for _, column := range columns {
+		document = append(document, fmt.Sprintf("coalesce(%s, '')", column))
+	}
+	return strings.Join(document, " || ' ' || ")
+}
+
+// conflictTargetUpsert ON CONFLICT DO UPDATE 必须指定冲突的唯一键
+func conflictTargetUpsert(dialect Dialect, spec UpsertSpec) (clause.Expression, error) {
+	if !spec.DoNothing && len(spec.ConflictColumns) == 0 {
+		return nil, errors.Errorf("upsert: %s requires conflict columns", dialect.Name())
+	}
+	return spec.onConflict(), nil
+}
+
+// excludedUpsertResult 冲突时不会区分插入和更新，只有 DoNothing 时的行数都是插入
+func excludedUpsertResult(spec UpsertSpec, affected int64) UpsertResult {
+	if spec.DoNothing {
+		return UpsertResult{RowsAffected: affected, Inserted: affected, CountsReported: true}
+	}
+	return UpsertResult{RowsAffected: affected}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return DialectSQLite
+}
+
+func (sqliteDialect) Quote(name string) string {
+	return quoteWith(name, `"`)
+}
+
+func (d sqliteDialect) Match(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "full-text match")
+}
+
+func (d sqliteDialect) MatchScore(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "full-text match")
+}
+
+func (sqliteDialect) JSONContains(column string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = json_extract(?, '$'))", column), []interface{}{value}, nil
+}
+
+func (sqliteDialect) JSONEq(column, path string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("json_extract(%s, ?) = ?", column), []interface{}{path, value}, nil
+}
+
+func (sqliteDialect) JSONHasKey(column, path string) (string, []interface{}, error) {
+	return fmt.Sprintf("json_type(%s, ?) IS NOT NULL", column), []interface{}{path}, nil
+}
+
+func (sqliteDialect) JSONArrayContains(column, path string, value interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s, ?) WHERE json_each.value = ?)", column), []interface{}{path, value}, nil
+}
+
+func (sqliteDialect) JSONLengthGt(column, path string, length interface{}) (string, []interface{}, error) {
+	return fmt.Sprintf("json_array_length(%s, ?) > ?", column), []interface{}{path, length}, nil
+}
+
+func (sqliteDialect) InsertIgnore() (clause.Expression, error) {
+	return clause.OnConflict{DoNothing: true}, nil
+}
+
+func (d sqliteDialect) Upsert(spec UpsertSpec) (clause.Expression, error) {
+	return conflictTargetUpsert(d, spec)
+}
+
+func (sqliteDialect) UpsertResult(spec UpsertSpec, rows int64, affected int64) UpsertResult {
+	return excludedUpsertResult(spec, affected)
+}
+
+func (sqliteDialect) MaxPlaceholders() int {
+	return 32766
+}
+
+func (sqliteDialect) IsDuplicateError(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// unsupportedDialect 没有用 RegisterDialect 注册的数据库，只支持 gorm 本身能生成的 sql
+type unsupportedDialect struct {
+	dialector gorm.Dialector
+}
+
+func (d unsupportedDialect) Name() string {
+	return d.dialector.Name()
+}
+
+func (d unsupportedDialect) Quote(name string) string {
+	if strings.ContainsAny(name, " ()") {
+		return name
+	}
+	builder := &strings.Builder{}
+	d.dialector.QuoteTo(builder, name)
+	return builder.String()
+}
+
+func (d unsupportedDialect) Match(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "full-text match")
+}
+
+func (d unsupportedDialect) MatchScore(columns []string, value interface{}, opt MatchOptions) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "full-text match")
+}
+
+func (d unsupportedDialect) JSONContains(column string, value interface{}) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "json_contains")
+}
+
+func (d unsupportedDialect) JSONEq(column, path string, value interface{}) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "json_eq")
+}
+
+func (d unsupportedDialect) JSONHasKey(column, path string) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "json_has_key")
+}
+
+func (d unsupportedDialect) JSONArrayContains(column, path string, value interface{}) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "json_array_contains")
+}
+
+func (d unsupportedDialect) JSONLengthGt(column, path string, length interface{}) (string, []interface{}, error) {
+	return "", nil, unsupported(d, "json_length_gt")
+}
+
+func (d unsupportedDialect) InsertIgnore() (clause.Expression, error) {
+	return nil, unsupported(d, "insert ignore")
+}
+
+func (d unsupportedDialect) Upsert(spec UpsertSpec) (clause.Expression, error) {
+	return nil, unsupported(d, "upsert")
+}
+
+func (unsupportedDialect) UpsertResult(spec UpsertSpec, rows int64, affected int64) UpsertResult {
+	return UpsertResult{RowsAffected: affected}
+}
+
+// MaxPlaceholders 不知道数据库的上限，按 sqlite 旧版本的 999 计算
+func (unsupportedDialect) MaxPlaceholders() int {
+	return 999
+}
+
+func (unsupportedDialect) IsDuplicateError(err error) bool {
+	return false
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"reflect"
+	"testing"
+)
+
+type dialectCase struct {
+	name   string
+	render func(d Dialect) (string, []interface{}, error)
+	want   map[string]string
+	args   map[string][]interface{}
+}
+
+func TestDialectRenderers(t *testing.T) {
+	cases := []dialectCase{
+		{
+			name: "match",
+			render: func(d Dialect) (string, []interface{}, error) {
+				return d.Match([]string{"title", "body"}, "go", MatchOptions{})
+			},
+			want: map[string]string{
+				DialectMySQL:    "match(title,body) against(? in NATURAL LANGUAGE MODE)",
+				DialectPostgres: "to_tsvector(coalesce(title, '') || ' ' || coalesce(body, '')) @@ plainto_tsquery(?)",
+			},
+			args: map[string][]interface{}{
+				DialectMySQL:    {"go"},
+				DialectPostgres: {"go"},
+			},
+		},
+		{
+			name: "json_eq",
+			render: func(d Dialect) (string, []interface{}, error) {
+				return d.JSONEq("attrs", "$.color", "red")
+			},
+			want: map[string]string{
+				DialectMySQL:    "JSON_UNQUOTE(JSON_EXTRACT(attrs, ?)) = ?",
+				DialectPostgres: "jsonb_extract_path_text(attrs::jsonb, ?) = ?",
+				DialectSQLite:   "json_extract(attrs, ?) = ?",
+			},
+			args: map[string][]interface{}{
+				DialectMySQL:    {"$.color", "red"},
+				DialectPostgres: {"color", "red"},
+				DialectSQLite:   {"$.color", "red"},
+			},
+		},
+		{
+			name: "json_has_key",
+			render: func(d Dialect) (string, []interface{}, error) {
+				return d.JSONHasKey("attrs", "$.size")
+			},
+			want: map[string]string{
+				DialectMySQL:    "JSON_CONTAINS_PATH(attrs, 'one', ?)",
+				DialectPostgres: "jsonb_exists(attrs::jsonb, ?)",
+				DialectSQLite:   "json_type(attrs, ?) IS NOT NULL",
+			},
+			args: map[string][]interface{}{
+				DialectMySQL:    {"$.size"},
+				DialectPostgres: {"size"},
+				DialectSQLite:   {"$.size"},
+			},
+		},
+		{
+			name: "json_array_contains",
+			render: func(d Dialect) (string, []interface{}, error) {
+				return d.JSONArrayContains("attrs", "$.tags", "x")
+			},
+			want: map[string]string{
+				DialectMySQL:    "JSON_CONTAINS(attrs, ?, ?)",
+				DialectPostgres: "jsonb_extract_path(attrs::jsonb, ?) @> CAST(? AS jsonb)",
+				DialectSQLite:   "EXISTS (SELECT 1 FROM json_each(attrs, ?) WHERE json_each.value = ?)",
+			},
+			args: map[string][]interface{}{
+				DialectMySQL:    {`"x"`, "$.tags"},
+				DialectPostgres: {"tags", `["x"]`},
+				DialectSQLite:   {"$.tags", "x"},
+			},
+		},
+		{
+			name: "json_length_gt",
+			render: func(d Dialect) (string, []interface{}, error) {
+				return d.JSONLengthGt("attrs", "$.tags", 2)
+			},
+			want: map[string]string{
+				DialectMySQL:    "JSON_LENGTH(attrs, ?) > ?",
+				DialectPostgres: "jsonb_array_length(jsonb_extract_path(attrs::jsonb, ?)) > ?",
+				DialectSQLite:   "json_array_length(attrs, ?) > ?",
+			},
+			args: map[string][]interface{}{
+				DialectMySQL:    {"$.tags", 2},
+				DialectPostgres: {"tags", 2},
+				DialectSQLite:   {"$.tags", 2},
+			},
+		},
+	}
+
+	dialects := []Dialect{mysqlDialect{}, postgresDialect{}, sqliteDialect{}}
+	for _, c := range cases {
+		for _, d := range dialects {
+			sql, args, err := c.render(d)
+			want, ok := c.want[d.Name()]
+			if !ok {
+				if !errors.Is(err, ErrDialectNotSupported) {
+					t.Errorf("%s/%s: got %q, %v, want ErrDialectNotSupported", c.name, d.Name(), sql, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s/%s: unexpected error %v", c.name, d.Name(), err)
+				continue
+			}
+			if sql != want {
+				t.Errorf("%s/%s: got %q, want %q", c.name, d.Name(), sql, want)
+			}
+			if !reflect.DeepEqual(args, c.args[d.Name()]) {
+				t.Errorf("%s/%s: got args %v, want %v", c.name, d.Name(), args, c.args[d.Name()])
+			}
+		}
+	}
+}
+
+func TestDialectQuote(t *testing.T) {
+	cases := map[Dialect]string{
+		mysqlDialect{}:    "`orders`.`user_id`",
+		postgresDialect{}: `"orders"."user_id"`,
+		sqliteDialect{}:   `"orders"."user_id"`,
+	}
+	for d, want := range cases {
+		if got := d.Quote("orders.user_id"); got != want {
+			t.Errorf("%s: got %s, want %s", d.Name(), got, want)
+		}
+		if got := d.Quote("count(*)"); got != "count(*)" {
+			t.Errorf("%s: expressions must not be quoted, got %s", d.Name(), got)
+		}
+	}
+}
+
+type unknownDialector struct {
+	gorm.Dialector
+}
+
+func (unknownDialector) Name() string {
+	return "sqlserver"
+}
+
+func TestGetDialectUnknown(t *testing.T) {
+	d := getDialect(&gorm.DB{Config: &gorm.Config{Dialector: unknownDialector{}}})
+	if d.Name() != "sqlserver" {
+		t.Fatalf("got dialect %s, want sqlserver", d.Name())
+	}
+	if _, _, err := d.JSONHasKey("attrs", "$.size"); !errors.Is(err, ErrDialectNotSupported) {
+		t.Fatalf("got %v, want ErrDialectNotSupported", err)
+	}
+	if _, err := d.InsertIgnore(); !errors.Is(err, ErrDialectNotSupported) {
+		t.Fatalf("got %v, want ErrDialectNotSupported", err)
+	}
+
+	RegisterDialect("sqlserver", sqliteDialect{})
+	defer func() {
+		dialectsMu.Lock()
+		delete(dialects, "sqlserver")
+		dialectsMu.Unlock()
+	}()
+	if d := getDialect(&gorm.DB{Config: &gorm.Config{Dialector: unknownDialector{}}}); d.Name() != DialectSQLite {
+		t.Fatalf("registered dialect is not used, got %s", d.Name())
+	}
+}
+
+func TestMySQLUpsertResult(t *testing.T) {
+	cases := []struct {
+		name     string
+		spec     UpsertSpec
+		rows     int64
+		affected int64
+		want     UpsertResult
+	}{
+		{"do nothing", UpsertSpec{DoNothing: true}, 3, 2, UpsertResult{RowsAffected: 2, Inserted: 2, CountsReported: true}},
+		{"single insert", UpsertSpec{}, 1, 1, UpsertResult{RowsAffected: 1, Inserted: 1, CountsReported: true}},
+		{"single update", UpsertSpec{}, 1, 2, UpsertResult{RowsAffected: 2, Updated: 1, CountsReported: true}},
+		{"unchanged", UpsertSpec{}, 2, 0, UpsertResult{CountsReported: true}},
+		{"all updated", UpsertSpec{}, 3, 6, UpsertResult{RowsAffected: 6, Updated: 3, CountsReported: true}},
+		{"ambiguous", UpsertSpec{}, 3, 3, UpsertResult{RowsAffected: 3}},
+	}
+	for _, c := range cases {
+		if got := (mysqlDialect{}).UpsertResult(c.spec, c.rows, c.affected); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	JoinInner = "INNER JOIN"
+	JoinLeft  = "LEFT JOIN"
+	JoinRight = "RIGHT JOIN"
+
+	sessionCountJoinsKey = "gorm_where:count_joins"
+)
+
+// JoinSpec 关联查询，Conditions 中的参数通过 Args 绑定
+type JoinSpec struct {
+	Type       string
+	Table      string
+	Alias      string
+	Conditions []string
+	Args       []interface{}
+}
+
+// NewJoin joinType 为 JoinInner/JoinLeft/JoinRight
+func NewJoin(joinType string, table string) *JoinSpec {
+	return &JoinSpec{Type: joinType, Table: table}
+}
+
+func (j *JoinSpec) As(alias string) *JoinSpec {
+	j.Alias = alias
+	return j
+}
+
+// On 多次调用时使用 AND 连接
+func (j *JoinSpec) On(condition string, args ...interface{}) *JoinSpec {
+	j.Conditions = append(j.Conditions, condition)
+	j.Args = append(j.Args, args...)
+	return j
+}
+
+// Name 条件中引用关联表时使用的名字
+func (j *JoinSpec) Name() string {
+	if len(j.Alias) != 0 {
+		return j.Alias
+	}
+	return j.Table
+}
+
+func (j *JoinSpec) build(dialect Dialect) string {
+	sql := fmt.Sprintf("%s %s", strings.ToUpper(j.Type), dialect.Quote(j.Table))
+	if len(j.Alias) != 0 {
+		sql += " AS " + dialect.Quote(j.Alias)
+	}
+	if len(j.Conditions) != 0 {
+		sql += " ON " + strings.Join(j.Conditions, " AND ")
+	}
+	return sql
+}
+
+func (j *JoinSpec) apply(session *gorm.DB) *gorm.DB {
+	return session.Joins(j.build(getDialect(session)), j.Args...)
+}
+
+// referencedBy where 条件中是否出现了 name.column 或 `name`.column
+func (j *JoinSpec) referencedBy(sql string) bool {
+	name := regexp.QuoteMeta(j.Name())
+	return regexp.MustCompile("(^|[^\\w])[`\"]?" + name + "[`\"]?\\.").MatchString(sql)
+}
+
+var (
+	joinsMu sync.RWMutex
+	joins   = map[string]*JoinSpec{}
+)
+
+// RegisterJoin 注册命名的 join，查询 struct 中通过 session:"users.name,join:user" 引用
+func RegisterJoin(name string, join *JoinSpec) {
+	joinsMu.Lock()
+	defer joinsMu.Unlock()
+	joins[name] = join
+}
+
+// parseJoinTag 解析 join tag，支持注册过的名字或 [inner|left|right] table [[as] alias] on condition
+func parseJoinTag(tag string) (*JoinSpec, error) {
+	joinsMu.RLock()
+	join, ok := joins[tag]
+	joinsMu.RUnlock()
+	if ok {
+		return join, nil
+	}
+
+	lower := strings.ToLower(tag)
+	index := strings.Index(lower, " on ")
+	if index < 0 {
+		return nil, errors.Errorf("join %s is neither registered nor in the form of \"table on condition\"", tag)
+	}
+
+	joinType := JoinInner
+	target := strings.Fields(tag[:index])
+	if len(target) != 0 {
+		switch strings.ToLower(target[0]) {
+		case "inner":
+			target = target[1:]
+		case "left":
+			joinType, target = JoinLeft, target[1:]
+		case "right":
+			joinType, target = JoinRight, target[1:]
+		}
+	}
+	if len(target) == 3 && strings.ToLower(target[1]) == "as" {
+		target = []string{target[0], target[2]}
+	}
+	if len(target) == 0 || len(target) > 2 {
+		return nil, errors.Errorf("join %s: invalid table", tag)
+	}
+
+	join = NewJoin(joinType, target[0]).On(strings.TrimSpace(tag[index+4:]))
+	if len(target) == 2 {
+		join.As(target[1])
+	}
+	return join, nil
+}
+
+// applyCountJoins count 查询中没有参数的 left join 只在 where 条件（以及 extra 中的 sql）或其它保留的 join 的 on 条件引用到时保留，
+// inner、right join 以及带参数的 on 条件会影响行数，总是保留；条件中有无法确定属于主表的未限定列名时保留所有 join
+func applyCountJoins(session *gorm.DB, extra ...string) *gorm.DB {
+	value, ok := session.Get(sessionCountJoinsKey)
+	if !ok {
+		return session
+	}
+
+	where := ""
+	if c, ok := session.Statement.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			where = expressionText(w.Exprs)
+		}
+	}
+	where = strings.Join(append(extra, where), " ")
+
+	specs := value.([]*JoinSpec)
+	keep := make([]bool, len(specs))
+	ambiguous := hasUnknownColumn(session, where)
+	for i, join := range specs {
+		keep[i] = ambiguous || join.Type != JoinLeft || len(join.Args) != 0 || join.referencedBy(where)
+	}
+
+	// 保留的 join 的 on 条件可能引用其它 left join，直到没有新的 join 需要保留
+	for changed := true; changed; {
+		changed = false
+		text := ""
+		for i, join := range specs {
+			if keep[i] {
+				text += " " + strings.Join(join.Conditions, " ")
+			}
+		}
+		for i, join := range specs {
+			if !keep[i] && join.referencedBy(text) {
+				keep[i], changed = true, true
+			}
+		}
+	}
+
+	for i, join := range specs {
+		if keep[i] {
+			session = join.apply(session)
+		}
+	}
+	return session
+}
+
+var (
+	sqlLiteral = regexp.MustCompile(`'(?:[^']|'')*'|::\w+|@\w+`)
+	sqlToken   = regexp.MustCompile("(`[^`]+`|\"[^\"]+\"|[\\w$]+)(\\s*\\.\\s*(?:`[^`]+`|\"[^\"]+\"|[\\w$]+))?(\\s*\\()?")
+	sqlKeyword = map[string]bool{}
+)
+
+func init() {
+	for _, keyword := range strings.Fields(`and or not in is null like ilike rlike regexp between true false exists
+		select from where as case when then else end asc desc distinct all any some union on join inner left right
+		outer cross group by having order limit offset against natural language mode boolean with query expansion
+		collate binary interval escape div mod xor unknown similar to microsecond second minute hour day week month
+		quarter year current_date current_time current_timestamp`) {
+		sqlKeyword[keyword] = true
+	}
+}
+
+// hasUnknownColumn sql 中是否有不带表名、也不是主表字段的列名，这类列可能属于某个 join 的表
+func hasUnknownColumn(session *gorm.DB, sql string) bool {
+	columns := map[string]bool{}
+	if session.Statement.Model != nil && session.Statement.Parse(session.Statement.Model) == nil {
+		for _, name := range session.Statement.Schema.DBNames {
+			columns[name] = true
+		}
+	}
+
+	alias := false
+	for _, token := range sqlToken.FindAllStringSubmatch(sqlLiteral.ReplaceAllString(sql, "?"), -1) {
+		name := strings.Trim(token[1], "`\"")
+		lower := strings.ToLower(name)
+		isAlias := alias
+		alias = lower == "as"
+		if isAlias || len(token[2]) != 0 || len(token[3]) != 0 || sqlKeyword[lower] || name[0] >= '0' && name[0] <= '9' {
+			continue
+		}
+		if !columns[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func expressionText(exprs []clause.Expression) string {
+	text := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case clause.Expr:
+			text = append(text, e.SQL)
+		case clause.NamedExpr:
+			text = append(text, e.SQL)
+		case clause.AndConditions:
+			text = append(text, expressionText(e.Exprs))
+		case clause.OrConditions:
+			text = append(text, expressionText(e.Exprs))
+		case clause.NotConditions:
+			text = append(text, expressionText(e.Exprs))
+		case clause.Eq:
+			text = append(text, columnText(e.Column))
+		case clause.Neq:
+			text = append(text, columnText(e.Column))
+		case clause.Gt:
+			text = append(text, columnText(e.Column))
+		case clause.Gte:
+			text = append(text, columnText(e.Column))
+		case clause.Lt:
+			text = append(text, columnText(e.Column))
+		case clause.Lte:
+			text = append(text, columnText(e.Column))
+		case clause.IN:
+			text = append(text, columnText(e.Column))
+		case clause.Like:
+			text = append(text, columnText(e.Column))
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+func columnText(column interface{}) string {
+	if c, ok := column.(clause.Column); ok {
+		if len(c.Table) != 0 {
+			return c.Table + "." + c.Name
+		}
+		return c.Name
+	}
+	return fmt.Sprint(column)
+}
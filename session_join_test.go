@@ -0,0 +1,146 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func countJoinSQL(t *testing.T, opts ...SessionOption) string {
+	session := newTestDB(t, DialectMySQL).Session(&gorm.Session{DryRun: true}).Model(&testOrder{})
+	for _, opt := range opts {
+		session = processSessionOptionForCount(opt, session)
+	}
+	var count int64
+	stmt := applyCountJoins(session).Count(&count).Statement
+	return stmt.SQL.String()
+}
+
+func TestApplyCountJoins(t *testing.T) {
+	users := SessionCondition.WithJoinSpec(NewJoin(JoinLeft, "users").On("users.id = test_orders.user_id"))
+	teams := SessionCondition.WithJoinSpec(NewJoin(JoinInner, "teams").On("teams.id = users.team_id"))
+	shops := SessionCondition.WithJoinSpec(NewJoin(JoinLeft, "shops").As("s").On("s.id = test_orders.shop_id"))
+
+	cases := []struct {
+		name string
+		opts []SessionOption
+		want []string
+		skip []string
+	}{
+		{
+			name: "unreferenced left join",
+			opts: []SessionOption{users, SessionCondition.WithEqual("status", "paid")},
+			skip: []string{"users"},
+		},
+		{
+			name: "referenced by where",
+			opts: []SessionOption{users, SessionCondition.WithEqual("users.name", "tom")},
+			want: []string{"LEFT JOIN `users`"},
+		},
+		{
+			name: "referenced by alias",
+			opts: []SessionOption{shops, users, SessionCondition.WithEqual("`s`.city", "sh")},
+			want: []string{"LEFT JOIN `shops` AS `s`"},
+			skip: []string{"users"},
+		},
+		{
+			name: "referenced by a kept join",
+			opts: []SessionOption{users, teams},
+			want: []string{"LEFT JOIN `users` ON users.id = test_orders.user_id INNER JOIN `teams`"},
+		},
+		{
+			name: "unqualified main table column",
+			opts: []SessionOption{users, SessionCondition.WithWhere("amount > ? AND status IN ?", 10, []string{"paid"})},
+			skip: []string{"users"},
+		},
+		{
+			name: "unqualified joined column",
+			opts: []SessionOption{users, SessionCondition.WithEqual("nickname", "tom")},
+			want: []string{"LEFT JOIN `users`"},
+		},
+	}
+	for _, c := range cases {
+		sql := countJoinSQL(t, c.opts...)
+		for _, want := range c.want {
+			if !strings.Contains(sql, want) {
+				t.Errorf("%s: sql %q does not contain %q", c.name, sql, want)
+			}
+		}
+		for _, skip := range c.skip {
+			if strings.Contains(sql, skip) {
+				t.Errorf("%s: sql %q must not join %q", c.name, sql, skip)
+			}
+		}
+	}
+}
+
+func TestHasUnknownColumn(t *testing.T) {
+	session := newTestDB(t, DialectMySQL).Model(&testOrder{})
+	cases := []struct {
+		sql     string
+		unknown bool
+	}{
+		{"status = ? AND amount BETWEEN ? AND ?", false},
+		{"lower(status) LIKE 'a.b%' AND users.name IS NOT NULL", false},
+		{"count(*) AS total", false},
+		{"`status` IN ?", false},
+		{"nickname = ?", true},
+		{"id IN (SELECT order_id FROM refunds)", true},
+	}
+	for _, c := range cases {
+		if got := hasUnknownColumn(session, c.sql); got != c.unknown {
+			t.Errorf("%s: got %v, want %v", c.sql, got, c.unknown)
+		}
+	}
+}
+
+func TestParseJoinTag(t *testing.T) {
+	registered := NewJoin(JoinLeft, "users").As("u").On("u.id = orders.user_id")
+	RegisterJoin("test_user", registered)
+
+	cases := []struct {
+		tag   string
+		want  *JoinSpec
+		error bool
+	}{
+		{"test_user", registered, false},
+		{"users on users.id = orders.user_id", &JoinSpec{Type: JoinInner, Table: "users", Conditions: []string{"users.id = orders.user_id"}}, false},
+		{"LEFT users u ON u.id = orders.user_id", &JoinSpec{Type: JoinLeft, Table: "users", Alias: "u", Conditions: []string{"u.id = orders.user_id"}}, false},
+		{"right users as u on u.id = orders.user_id", &JoinSpec{Type: JoinRight, Table: "users", Alias: "u", Conditions: []string{"u.id = orders.user_id"}}, false},
+		{"inner shops on shops.id = orders.shop_id and shops.open = 1", &JoinSpec{Type: JoinInner, Table: "shops", Conditions: []string{"shops.id = orders.shop_id and shops.open = 1"}}, false},
+		{"unknown", nil, true},
+		{"left on u.id = orders.user_id", nil, true},
+		{"users u x on u.id = orders.user_id", nil, true},
+	}
+	for _, c := range cases {
+		join, err := parseJoinTag(c.tag)
+		if c.error {
+			if err == nil {
+				t.Errorf("%s: want error, got %+v", c.tag, join)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.tag, err)
+			continue
+		}
+		if !reflect.DeepEqual(join, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.tag, join, c.want)
+		}
+	}
+}
+
+func TestParseSessionOptionJoinTag(t *testing.T) {
+	type query struct {
+		UserName string `session:"users.name,join:left users on users.id = test_orders.user_id"`
+		UserCity string `session:"users.city,join:left users on users.id = test_orders.user_id"`
+	}
+	sql := findSQL(newTestDB(t, DialectMySQL), &[]testOrder{}, ParseSessionOption(&query{UserName: "tom", UserCity: "sh"})...)
+	if strings.Count(sql, "LEFT JOIN `users`") != 1 {
+		t.Errorf("the same join tag must be joined once: %s", sql)
+	}
+	if !strings.Contains(sql, "users.name = 'tom'") || !strings.Contains(sql, "users.city = 'sh'") {
+		t.Errorf("missing conditions: %s", sql)
+	}
+}
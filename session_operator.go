@@ -0,0 +1,150 @@
+package database
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TagOptions 自定义 op 可以读取的 session tag 配置
+type TagOptions struct {
+	Name     string
+	Op       string
+	Columns  []string
+	Default  string
+	Empty    bool
+	Layout   string
+	Path     string
+	Join     string
+	Match    MatchOptions
+	Location *time.Location
+
+	opt sessionOptionTag
+}
+
+// OperatorFunc 根据字段值生成查询条件，返回 Process 为 nil 的 SessionOption 表示忽略该字段
+type OperatorFunc func(column string, value reflect.Value, tag TagOptions) (SessionOption, error)
+
+var (
+	operatorsMu sync.RWMutex
+	operators   = map[string]OperatorFunc{}
+)
+
+// RegisterOperator 注册自定义 op，同名会覆盖内置 op
+func RegisterOperator(name string, fn OperatorFunc) {
+	operatorsMu.Lock()
+	defer operatorsMu.Unlock()
+	operators[name] = fn
+}
+
+func getOperator(name string) (OperatorFunc, bool) {
+	operatorsMu.RLock()
+	defer operatorsMu.RUnlock()
+	fn, ok := operators[name]
+	return fn, ok
+}
+
+func newTagOptions(opt sessionOptionTag, columns []string) TagOptions {
+	return TagOptions{
+		Name:     opt.name,
+		Op:       opt.op,
+		Columns:  columns,
+		Default:  opt.defaultValue,
+		Empty:    opt.empty,
+		Layout:   opt.layout,
+		Path:     opt.path,
+		Join:     opt.join,
+		Match:    opt.match,
+		Location: opt.location,
+		opt:      opt,
+	}
+}
+
+func init() {
+	RegisterOperator("equal", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithEqual(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("not_equal", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithNotEqual(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("like", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithLike(column, loadString(value, tag.opt)), nil
+	})
+	RegisterOperator("like_or", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithLikeOr(loadString(value, tag.opt), tag.Columns...), nil
+	})
+	RegisterOperator("in", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithIn(column, loadSlice(value)...), nil
+	})
+	RegisterOperator("not_in", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithNotIn(column, loadSlice(value)...), nil
+	})
+	RegisterOperator("lt", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithLt(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("lte", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithLte(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("gt", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithGt(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("gte", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithGte(column, loadValue(value.Interface(), tag.opt)), nil
+	})
+	RegisterOperator("between", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		from, to, excludeFrom, excludeTo, ok := loadRange(value)
+		if !ok {
+			return SessionOption{}, errors.Errorf("op:between does not support type %s", value.Type())
+		}
+		return SessionCondition.WithRange(column, loadValue(from, tag.opt), loadValue(to, tag.opt), excludeFrom, excludeTo), nil
+	})
+	RegisterOperator("date", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		day, ok := loadTime(value.Interface(), tag.opt)
+		if !ok {
+			return SessionOption{}, errors.Errorf("op:date can not parse %v", value.Interface())
+		}
+		return SessionCondition.WithDate(column, day), nil
+	})
+	RegisterOperator("json_contains", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithJSONContains(column, value.Interface()), nil
+	})
+	RegisterOperator("json_eq", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithJSONEq(column, tag.Path, value.Interface()), nil
+	})
+	RegisterOperator("json_has_key", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		path := tag.Path
+		if len(path) == 0 {
+			path = "$." + loadString(value, tag.opt)
+		}
+		return SessionCondition.WithJSONHasKey(column, path), nil
+	})
+	RegisterOperator("json_array_contains", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithJSONArrayContains(column, jsonPathOrRoot(tag.Path), value.Interface()), nil
+	})
+	RegisterOperator("json_length_gt", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithJSONLengthGt(column, jsonPathOrRoot(tag.Path), value.Interface()), nil
+	})
+	for op, operator := range map[string]string{
+		"having_eq":  "=",
+		"having_gt":  ">",
+		"having_gte": ">=",
+		"having_lt":  "<",
+		"having_lte": "<=",
+	} {
+		operator := operator
+		RegisterOperator(op, func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+			return SessionCondition.WithHaving(column+" "+operator+" ?", value.Interface()), nil
+		})
+	}
+	RegisterOperator("match", func(column string, value reflect.Value, tag TagOptions) (SessionOption, error) {
+		return SessionCondition.WithMatch(tag.Columns, value.Interface(), tag.Match), nil
+	})
+}
+
+func jsonPathOrRoot(path string) string {
+	if len(path) == 0 {
+		return "$"
+	}
+	return path
+}
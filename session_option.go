@@ -1,7 +1,8 @@
 package database
 
 import (
-	"fmt"
+	"context"
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"reflect"
 	"strings"
@@ -19,16 +20,50 @@ const (
 	sessionOptionJoin        = 8
 	sessionOptionNoCount     = 9
 	sessionOptionMatch       = 10
+	sessionOptionPreload     = 11
+	sessionOptionBatch       = 12
+	sessionOptionLock        = 13
+	sessionOptionGuard       = 14
+	sessionOptionScope       = 15
+	sessionOptionHaving      = 16
 )
 
 type SessionOption struct {
 	Type    int
 	Process func(builder *gorm.DB) *gorm.DB
+	// CountProcess 不为 nil 时 count 查询使用它代替 Process
+	CountProcess func(builder *gorm.DB) *gorm.DB
 }
 
 type SessionOptionList []SessionOption
 
 func ParseSessionOption(data interface{}) []SessionOption {
+	return ParseSessionOptionContext(context.Background(), data)
+}
+
+// ParseSessionOptionContext 同 ParseSessionOption，default:ctx:xxx 从 ctx 中取值
+func ParseSessionOptionContext(ctx context.Context, data interface{}) []SessionOption {
+	result, _ := parseSessionOption(ctx, data, false)
+	return result
+}
+
+// ParseSessionOptionE 严格模式，tag 不合法或默认值无法解析时返回错误
+func ParseSessionOptionE(data interface{}) ([]SessionOption, error) {
+	return ParseSessionOptionContextE(context.Background(), data)
+}
+
+func ParseSessionOptionContextE(ctx context.Context, data interface{}) ([]SessionOption, error) {
+	elem := GetElem(reflect.ValueOf(data))
+	if elem.Kind() != reflect.Struct {
+		return nil, errors.Errorf("ParseSessionOption fail: %T is not a struct", data)
+	}
+	if err := Validate(elem.Type()); err != nil {
+		return nil, err
+	}
+	return parseSessionOption(ctx, data, true)
+}
+
+func parseSessionOption(ctx context.Context, data interface{}, strict bool) ([]SessionOption, error) {
 	result := make([]SessionOption, 0)
 
 	elem := GetElem(reflect.ValueOf(data))
@@ -39,29 +74,46 @@ func ParseSessionOption(data interface{}) []SessionOption {
 	pageSize := 10
 	hasPage := true
 	hasCount := true
+	joined := make(map[string]bool)
 
-	for i := 0; i != elem.NumField(); i++ {
-		field := elem.Type().Field(i)
-		fieldValue := GetElem(elem.Field(i))
-		tag := field.Tag.Get("session")
-
-		opt := parseSessionOptionTag(tag)
-
-		if opt.name == "-" {
+	for _, field := range getSessionPlan(elem.Type()) {
+		if !field.exported {
 			continue
 		}
+		fieldValue := GetElem(elem.Field(field.index))
+		opt := field.opt
 
 		if omitEmpty(fieldValue, opt) {
 			continue
 		}
 
+		if len(opt.defaultValue) != 0 && isEmptyValue(fieldValue) {
+			def, err := loadDefault(ctx, field.typ, opt)
+			if err != nil {
+				if strict {
+					return nil, errors.Wrapf(err, "%s.%s", elem.Type().Name(), field.fieldName)
+				}
+				if strings.HasPrefix(opt.defaultValue, defaultValueCtxPrefix) {
+					// ctx 中没有值时不能去掉条件，否则租户之类的过滤会失效
+					result = append(result, SessionCondition.WithWhere("1 = 0"))
+				}
+				continue
+			}
+			fieldValue = def
+		}
+
 		if opt.name == "sort_by" {
 			sortBy = loadString(fieldValue, opt)
 			continue
 		}
 
 		if opt.name == "group_by" {
-			groupBy = loadString(fieldValue, opt)
+			// 没有 allow 时不允许任何分组，客户端传入的值不能直接拼到 sql 中
+			allowed, denied := loadAllowed(loadString(fieldValue, opt), opt)
+			if len(denied) != 0 && strict {
+				return nil, errors.Errorf("%s.%s: group by %s is not allowed", elem.Type().Name(), field.fieldName, strings.Join(denied, ","))
+			}
+			groupBy = strings.Join(allowed, ",")
 			continue
 		}
 
@@ -103,48 +155,58 @@ func ParseSessionOption(data interface{}) []SessionOption {
 			continue
 		}
 
-		switch opt.op {
-		case "equal":
-			result = append(result, SessionCondition.WithEqual(opt.name, fieldValue.Interface()))
-		case "not_equal":
-			result = append(result, SessionCondition.WithNotEqual(opt.name, fieldValue.Interface()))
-		case "like":
-			result = append(result, SessionCondition.WithLike(opt.name, loadString(fieldValue, opt)))
-		case "like_or":
-			result = append(result, SessionCondition.WithLikeOr(loadString(fieldValue, opt), strings.Split(opt.name, "&")...))
-		case "in":
-			in := make([]interface{}, fieldValue.Len())
-			for i := 0; i != fieldValue.Len(); i++ {
-				in[i] = fieldValue.Index(i).Interface()
+		if opt.name == "trashed" {
+			switch trashed := loadString(fieldValue, opt); trashed {
+			case TrashedWith:
+				result = append(result, SessionCondition.WithTrashed())
+			case TrashedOnly:
+				result = append(result, SessionCondition.OnlyTrashed())
+			default:
+				if strict {
+					return nil, errors.Errorf("%s.%s: trashed %s is not one of with|only", elem.Type().Name(), field.fieldName, trashed)
+				}
+			}
+			continue
+		}
+
+		if opt.name == "preload" {
+			allowed, denied := loadAllowed(fieldValue.Interface(), opt)
+			if len(denied) != 0 && strict {
+				return nil, errors.Errorf("%s.%s: preload %s is not allowed", elem.Type().Name(), field.fieldName, strings.Join(denied, ","))
 			}
-			result = append(result, SessionCondition.WithIn(opt.name, in...))
-		case "not_in":
-			in := make([]interface{}, fieldValue.Len())
-			for i := 0; i != fieldValue.Len(); i++ {
-				in[i] = fieldValue.Index(i).Interface()
+			for _, assoc := range allowed {
+				result = append(result, SessionCondition.WithPreload(assoc))
+			}
+			continue
+		}
+
+		if len(opt.join) != 0 && !joined[opt.join] {
+			join, err := parseJoinTag(opt.join)
+			if err != nil {
+				if strict {
+					return nil, errors.Wrapf(err, "%s.%s", elem.Type().Name(), field.fieldName)
+				}
+				continue
 			}
-			result = append(result, SessionCondition.WithNotIn(opt.name, in...))
-		case "lt":
-			result = append(result, SessionCondition.WithLt(opt.name, fieldValue.Interface()))
-		case "lte":
-			result = append(result, SessionCondition.WithLte(opt.name, fieldValue.Interface()))
-		case "gt":
-			result = append(result, SessionCondition.WithGt(opt.name, fieldValue.Interface()))
-		case "gte":
-			result = append(result, SessionCondition.WithGte(opt.name, fieldValue.Interface()))
-		case "json_contains":
-			result = append(
-				result,
-				SessionCondition.WithWhere(
-					fmt.Sprintf("JSON_CONTAINS(%s, ?)", opt.name),
-					fieldValue.Interface(),
-				),
-			)
-		case "match":
-			result = append(result, SessionCondition.WithMatch(strings.Split(opt.name, "&"), fieldValue.Interface()))
-		default:
-			result = append(result, SessionCondition.WithWhere(opt.op, fieldValue.Interface()))
+			joined[opt.join] = true
+			result = append(result, SessionCondition.WithJoinSpec(join))
 		}
+
+		if operator, ok := getOperator(opt.op); ok {
+			option, err := operator(opt.name, fieldValue, field.tag)
+			if err != nil {
+				if strict {
+					return nil, errors.Wrapf(err, "%s.%s", elem.Type().Name(), field.fieldName)
+				}
+				continue
+			}
+			if option.Process != nil {
+				result = append(result, option)
+			}
+			continue
+		}
+
+		result = append(result, SessionCondition.WithWhere(opt.op, fieldValue.Interface()))
 	}
 
 	if len(sortBy) != 0 {
@@ -163,15 +225,19 @@ func ParseSessionOption(data interface{}) []SessionOption {
 		result = append(result, SessionCondition.WithNoCount())
 	}
 
-	return result
+	return result, nil
 }
 
 func processSessionOptionForCount(searchOpt SessionOption, session *gorm.DB) *gorm.DB {
 	if searchOpt.Type == sessionOptionUpdateCols {
 		return session
 	}
+	if searchOpt.CountProcess != nil {
+		return searchOpt.CountProcess(session)
+	}
 	if searchOpt.Type != sessionOptionLimitation && searchOpt.Type != sessionOptionSelect &&
-		searchOpt.Type != sessionOptionOrderBy && searchOpt.Type != sessionOptionGroupBy {
+		searchOpt.Type != sessionOptionOrderBy && searchOpt.Type != sessionOptionGroupBy &&
+		searchOpt.Type != sessionOptionHaving && searchOpt.Type != sessionOptionPreload {
 		session = searchOpt.Process(session)
 	}
 	return session
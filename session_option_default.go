@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Now 解析 default:now/today 等动态默认值时使用的时钟，测试时可以替换
+var Now = time.Now
+
+const (
+	defaultValueNow       = "now"
+	defaultValueToday     = "today"
+	defaultValueCtxPrefix = "ctx:"
+	defaultValueListSep   = "|"
+)
+
+// isEmptyValue 字段没有传值，需要使用 default
+func isEmptyValue(value reflect.Value) bool {
+	if !value.IsValid() {
+		return true
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// loadDefault 按字段类型解析 default 值，支持 now-7d、today、ctx:tenant_id 等动态表达式
+func loadDefault(ctx context.Context, typ reflect.Type, opt sessionOptionTag) (reflect.Value, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	def := opt.defaultValue
+	if strings.HasPrefix(def, defaultValueCtxPrefix) {
+		key := strings.TrimPrefix(def, defaultValueCtxPrefix)
+		v := ctx.Value(key)
+		if v == nil {
+			return reflect.Value{}, errors.Errorf("default %s: %s not found in context", def, key)
+		}
+		return convertValue(reflect.ValueOf(v), typ, opt)
+	}
+
+	if t, ok, err := parseTimeExpression(def, opt); ok {
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return convertValue(reflect.ValueOf(t), typ, opt)
+	}
+
+	return parseLiteral(def, typ, opt)
+}
+
+func parseLiteral(str string, typ reflect.Type, opt sessionOptionTag) (reflect.Value, error) {
+	value := reflect.New(typ).Elem()
+
+	if typ == reflect.TypeOf(time.Time{}) {
+		t, ok := loadTime(str, opt)
+		if !ok {
+			return reflect.Value{}, errors.Errorf("default %s: invalid time", str)
+		}
+		value.Set(reflect.ValueOf(t))
+		return value, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		value.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "default %s", str)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "default %s", str)
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "default %s", str)
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "default %s", str)
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(str, defaultValueListSep)
+		value = reflect.MakeSlice(typ, 0, len(items))
+		for _, item := range items {
+			elem, err := parseLiteral(strings.TrimSpace(item), typ.Elem(), opt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value = reflect.Append(value, elem)
+		}
+	default:
+		return reflect.Value{}, errors.Errorf("default %s: unsupported kind %s", str, typ.Kind())
+	}
+
+	return value, nil
+}
+
+// parseTimeExpression 解析 now、today、now-7d、today+1d 等表达式，第二个返回值表示是否是时间表达式
+func parseTimeExpression(expr string, opt sessionOptionTag) (time.Time, bool, error) {
+	var base time.Time
+	var offset string
+
+	now := Now().In(opt.getLocation())
+	switch {
+	case strings.HasPrefix(expr, defaultValueToday):
+		base = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		offset = strings.TrimPrefix(expr, defaultValueToday)
+	case strings.HasPrefix(expr, defaultValueNow):
+		base = now
+		offset = strings.TrimPrefix(expr, defaultValueNow)
+	default:
+		return time.Time{}, false, nil
+	}
+
+	if len(offset) == 0 {
+		return base, true, nil
+	}
+	if offset[0] != '+' && offset[0] != '-' {
+		return time.Time{}, false, nil
+	}
+
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+	offset = offset[1:]
+
+	switch {
+	case strings.HasSuffix(offset, "d"), strings.HasSuffix(offset, "w"):
+		n, err := strconv.Atoi(offset[:len(offset)-1])
+		if err != nil {
+			return time.Time{}, true, errors.Wrapf(err, "default %s", expr)
+		}
+		if strings.HasSuffix(offset, "w") {
+			n *= 7
+		}
+		return base.AddDate(0, 0, sign*n), true, nil
+	default:
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return time.Time{}, true, errors.Wrapf(err, "default %s", expr)
+		}
+		return base.Add(time.Duration(sign) * d), true, nil
+	}
+}
+
+// convertValue 把动态默认值转换为字段类型，time.Time 可以转换为字符串(layout)或时间戳
+func convertValue(value reflect.Value, typ reflect.Type, opt sessionOptionTag) (reflect.Value, error) {
+	if value.Type().AssignableTo(typ) {
+		return value, nil
+	}
+
+	if t, ok := value.Interface().(time.Time); ok {
+		switch typ.Kind() {
+		case reflect.String:
+			layout := opt.layout
+			if len(layout) == 0 {
+				layout = DefaultDateLayout
+			}
+			return reflect.ValueOf(t.Format(layout)).Convert(typ), nil
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+			return reflect.ValueOf(t.Unix()).Convert(typ), nil
+		}
+	}
+
+	if value.Kind() == reflect.String && typ.Kind() != reflect.String {
+		return parseLiteral(value.String(), typ, opt)
+	}
+
+	if typ.Kind() == reflect.String {
+		switch {
+		case isIntKind(value.Kind()):
+			return reflect.ValueOf(strconv.FormatInt(value.Int(), 10)).Convert(typ), nil
+		case isUintKind(value.Kind()):
+			return reflect.ValueOf(strconv.FormatUint(value.Uint(), 10)).Convert(typ), nil
+		case isFloatKind(value.Kind()):
+			return reflect.ValueOf(strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits())).Convert(typ), nil
+		}
+	}
+
+	// 数字之间只做不丢失数据的转换，其它跨类型的转换（如 int 转 string 得到的 rune）一律报错
+	if isNumberKind(value.Kind()) && isNumberKind(typ.Kind()) {
+		if result, ok := convertNumber(value, typ); ok {
+			return result, nil
+		}
+	} else if value.Kind() == typ.Kind() && value.Type().ConvertibleTo(typ) {
+		return value.Convert(typ), nil
+	}
+
+	return reflect.Value{}, errors.Errorf("default %s: can not convert %s to %s", opt.defaultValue, value.Type(), typ)
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefault(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 4, 5, 0, time.UTC)
+	Now = func() time.Time { return now }
+	defer func() { Now = time.Now }()
+
+	type tenantID string
+	ctx := context.WithValue(context.Background(), "tenant_id", int64(65))
+	ctx = context.WithValue(ctx, "ratio", 1.5)
+	ctx = context.WithValue(ctx, "name", "shop")
+	ctx = context.WithValue(ctx, "big", int64(1)<<40)
+	ctx = context.WithValue(ctx, "tags", []string{"a"})
+
+	cases := []struct {
+		name  string
+		typ   reflect.Type
+		tag   string
+		want  interface{}
+		error string
+	}{
+		{"int literal", reflect.TypeOf(0), "limit,default:10", 10, ""},
+		{"pointer literal", reflect.TypeOf((*int64)(nil)), "limit,default:10", int64(10), ""},
+		{"bool literal", reflect.TypeOf(false), "enabled,default:true", true, ""},
+		{"slice literal", reflect.TypeOf([]int{}), "status,default:1|2", []int{1, 2}, ""},
+		{"bad literal", reflect.TypeOf(0), "limit,default:ten", nil, "default ten"},
+		{"now", reflect.TypeOf(time.Time{}), "created_at,default:now-7d,tz:UTC", now.AddDate(0, 0, -7), ""},
+		{"today string", reflect.TypeOf(""), "day,default:today", "2026-03-10", ""},
+		{"now unix", reflect.TypeOf(int64(0)), "ts,default:now", now.Unix(), ""},
+		{"ctx int to string", reflect.TypeOf(""), "tenant_id,default:ctx:tenant_id", "65", ""},
+		{"ctx int to named string", reflect.TypeOf(tenantID("")), "tenant_id,default:ctx:tenant_id", tenantID("65"), ""},
+		{"ctx float to string", reflect.TypeOf(""), "ratio,default:ctx:ratio", "1.5", ""},
+		{"ctx int64 to int", reflect.TypeOf(0), "tenant_id,default:ctx:tenant_id", 65, ""},
+		{"ctx overflow", reflect.TypeOf(int32(0)), "big,default:ctx:big", nil, "can not convert"},
+		{"ctx float to int", reflect.TypeOf(0), "ratio,default:ctx:ratio", nil, "can not convert"},
+		{"ctx string to int", reflect.TypeOf(0), "name,default:ctx:name", nil, "invalid syntax"},
+		{"ctx slice to string", reflect.TypeOf(""), "tags,default:ctx:tags", nil, "can not convert"},
+		{"ctx missing", reflect.TypeOf(""), "user_id,default:ctx:user_id", nil, "not found in context"},
+	}
+	for _, c := range cases {
+		value, err := loadDefault(ctx, c.typ, parseSessionOptionTag(c.tag))
+		if len(c.error) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.error) {
+				t.Errorf("%s: got %v, want error containing %q", c.name, err, c.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if got := value.Interface(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	cases := []struct {
+		str   string
+		typ   reflect.Type
+		want  interface{}
+		error bool
+	}{
+		{"web", reflect.TypeOf(""), "web", false},
+		{"-3", reflect.TypeOf(int8(0)), int8(-3), false},
+		{"300", reflect.TypeOf(int8(0)), nil, true},
+		{"-1", reflect.TypeOf(uint(0)), nil, true},
+		{"0.5", reflect.TypeOf(float32(0)), float32(0.5), false},
+		{"a | b", reflect.TypeOf([]string{}), []string{"a", "b"}, false},
+		{"1|x", reflect.TypeOf([]int{}), nil, true},
+		{"2026-01-02", reflect.TypeOf(time.Time{}), time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"{}", reflect.TypeOf(map[string]int{}), nil, true},
+	}
+	for _, c := range cases {
+		value, err := parseLiteral(c.str, c.typ, sessionOptionTag{location: time.UTC})
+		if c.error {
+			if err == nil {
+				t.Errorf("%s as %s: want error, got %v", c.str, c.typ, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s as %s: unexpected error %v", c.str, c.typ, err)
+			continue
+		}
+		if got := value.Interface(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s as %s: got %#v, want %#v", c.str, c.typ, got, c.want)
+		}
+	}
+}
+
+func TestParseSessionOptionContextDefault(t *testing.T) {
+	type query struct {
+		TenantID string `session:"tenant_id,default:ctx:tenant_id"`
+	}
+	ctx := context.WithValue(context.Background(), "tenant_id", 65)
+	if _, err := ParseSessionOptionContextE(ctx, &query{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ctx = context.WithValue(context.Background(), "tenant_id", []int{65})
+	if _, err := ParseSessionOptionContextE(ctx, &query{}); err == nil {
+		t.Fatalf("want error for a slice ctx value")
+	}
+}
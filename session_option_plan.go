@@ -0,0 +1,48 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// sessionFieldPlan 单个字段预先解析好的 session tag
+type sessionFieldPlan struct {
+	index     int
+	fieldName string
+	exported  bool
+	anonymous bool
+	typ       reflect.Type
+	opt       sessionOptionTag
+	tag       TagOptions
+}
+
+var sessionPlanCache sync.Map
+
+// getSessionPlan 按 struct 类型缓存 tag 解析结果，每次请求只需要读取字段值
+func getSessionPlan(typ reflect.Type) []sessionFieldPlan {
+	if plan, ok := sessionPlanCache.Load(typ); ok {
+		return plan.([]sessionFieldPlan)
+	}
+
+	plan := make([]sessionFieldPlan, 0, typ.NumField())
+	for i := 0; i != typ.NumField(); i++ {
+		field := typ.Field(i)
+		opt := parseSessionOptionTag(field.Tag.Get("session"))
+		if opt.name == "-" {
+			continue
+		}
+		plan = append(plan, sessionFieldPlan{
+			index:     i,
+			fieldName: field.Name,
+			exported:  field.IsExported(),
+			anonymous: field.Anonymous,
+			typ:       field.Type,
+			opt:       opt,
+			tag:       newTagOptions(opt, strings.Split(opt.name, "&")),
+		})
+	}
+
+	actual, _ := sessionPlanCache.LoadOrStore(typ, plan)
+	return actual.([]sessionFieldPlan)
+}
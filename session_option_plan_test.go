@@ -0,0 +1,88 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+type benchOrderQuery struct {
+	Keyword  string     `session:"title,op:like"`
+	Status   []int      `session:"status,op:in"`
+	UserId   int64      `session:"user_id"`
+	Amount   Range[int] `session:"amount,op:between"`
+	Channel  string     `session:"channel,default:web"`
+	SortBy   string     `session:"sort_by"`
+	Page     int        `session:"page"`
+	PageSize int        `session:"page_size"`
+	Secret   string     `session:"-"`
+	internal string
+}
+
+func TestGetSessionPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(benchOrderQuery{})
+	sessionPlanCache.Delete(typ)
+
+	plan := getSessionPlan(typ)
+	if len(plan) != typ.NumField()-1 {
+		t.Fatalf("plan has %d fields, want %d", len(plan), typ.NumField()-1)
+	}
+	for _, field := range plan {
+		if field.fieldName == "Secret" {
+			t.Fatalf("field tagged - must be skipped")
+		}
+	}
+	if plan[0].opt.name != "title" || plan[0].opt.op != "like" {
+		t.Fatalf("unexpected tag for Keyword: %+v", plan[0].opt)
+	}
+
+	cached := getSessionPlan(typ)
+	if &cached[0] != &plan[0] {
+		t.Fatalf("plan is not cached")
+	}
+}
+
+func TestParseSessionOptionUsesCachedPlan(t *testing.T) {
+	query := &benchOrderQuery{Keyword: "go", Status: []int{1, 2}, Page: 2}
+	first := ParseSessionOption(query)
+	second := ParseSessionOption(query)
+	if len(first) != len(second) {
+		t.Fatalf("got %d and %d options for the same query", len(first), len(second))
+	}
+}
+
+func newBenchOrderQuery() *benchOrderQuery {
+	return &benchOrderQuery{
+		Keyword:  "phone",
+		Status:   []int{1, 2, 3},
+		UserId:   42,
+		Amount:   Range[int]{From: 10, To: 100},
+		SortBy:   "-id",
+		Page:     3,
+		PageSize: 20,
+	}
+}
+
+// BenchmarkParseSessionOption 每次请求只读取字段值，tag 解析结果来自缓存
+func BenchmarkParseSessionOption(b *testing.B) {
+	query := newBenchOrderQuery()
+	ParseSessionOption(query)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseSessionOption(query)
+	}
+}
+
+// BenchmarkParseSessionOptionUncached 每次都重新解析 tag，对应加缓存之前的开销
+func BenchmarkParseSessionOptionUncached(b *testing.B) {
+	query := newBenchOrderQuery()
+	typ := reflect.TypeOf(*query)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sessionPlanCache.Delete(typ)
+		ParseSessionOption(query)
+	}
+}
@@ -1,6 +1,10 @@
 package database
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 type sessionOptionTag struct {
 	name         string
@@ -12,6 +16,13 @@ type sessionOptionTag struct {
 	page         int
 	pageSize     int
 	ignoreCopy   bool
+	layout       string
+	path         string
+	join         string
+	allow        []string
+	match        MatchOptions
+	location     *time.Location
+	invalid      []string
 }
 
 func parseSessionOptionTag(tag string) sessionOptionTag {
@@ -25,24 +36,45 @@ func parseSessionOptionTag(tag string) sessionOptionTag {
 	for _, tag := range tags[1:] {
 		tag = strings.Trim(tag, " ")
 
-		if tag == "empty" {
+		switch {
+		case tag == "empty":
 			option.empty = true
-		}
-
-		if tag == "no-update" {
+		case tag == "no-update":
 			option.noUpdate = true
-		}
-
-		if tag == "ignore-copy" {
+		case tag == "ignore-copy":
 			option.ignoreCopy = true
-		}
-
-		if strings.HasPrefix(tag, "default:") {
+		case strings.HasPrefix(tag, "default:"):
 			option.defaultValue = strings.TrimPrefix(tag, "default:")
-		}
-
-		if strings.HasPrefix(tag, "op:") {
+		case strings.HasPrefix(tag, "op:"):
 			option.op = strings.TrimPrefix(tag, "op:")
+		case strings.HasPrefix(tag, "layout:"):
+			option.layout = strings.TrimPrefix(tag, "layout:")
+		case strings.HasPrefix(tag, "allow:"):
+			option.allow = strings.Split(strings.TrimPrefix(tag, "allow:"), "|")
+		case strings.HasPrefix(tag, "join:"):
+			option.join = strings.TrimPrefix(tag, "join:")
+		case strings.HasPrefix(tag, "path:"):
+			option.path = strings.TrimPrefix(tag, "path:")
+		case strings.HasPrefix(tag, "mode:"):
+			option.match.Mode = strings.TrimPrefix(tag, "mode:")
+		case tag == "expansion":
+			option.match.QueryExpansion = true
+		case strings.HasPrefix(tag, "min_score:"):
+			score, err := strconv.ParseFloat(strings.TrimPrefix(tag, "min_score:"), 64)
+			if err != nil {
+				option.invalid = append(option.invalid, tag)
+			}
+			option.match.MinScore = score
+		case strings.HasPrefix(tag, "score:"):
+			option.match.ScoreColumn = strings.TrimPrefix(tag, "score:")
+		case strings.HasPrefix(tag, "tz:"):
+			location, err := time.LoadLocation(strings.TrimPrefix(tag, "tz:"))
+			if err != nil {
+				option.invalid = append(option.invalid, tag)
+			}
+			option.location = location
+		default:
+			option.invalid = append(option.invalid, tag)
 		}
 	}
 
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	sessionRangeType = reflect.TypeOf((*sessionRange)(nil)).Elem()
+)
+
+// Validate 检查查询 struct 的 session tag：未知 op、未知修饰符、无法解析的 default、字段类型与 op 不匹配
+func Validate(typ reflect.Type) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return errors.Errorf("Validate fail: %s is not a struct", typ)
+	}
+
+	problems := make([]string, 0)
+	for _, field := range getSessionPlan(typ) {
+		if err := validateSessionField(field); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.%s: %s", typ.Name(), field.fieldName, err))
+		}
+	}
+	if len(problems) != 0 {
+		return errors.Errorf("Validate fail: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// MustRegisterQuery 启动时检查查询 struct 并预热 tag 缓存，tag 不合法时 panic
+func MustRegisterQuery(query interface{}) {
+	typ := reflect.TypeOf(query)
+	if typ == nil {
+		panic("MustRegisterQuery: query is nil")
+	}
+	if err := Validate(typ); err != nil {
+		panic(err)
+	}
+}
+
+func validateSessionField(field sessionFieldPlan) error {
+	opt := field.opt
+	typ := field.typ
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if len(opt.invalid) != 0 {
+		return errors.Errorf("unknown modifier %s", strings.Join(opt.invalid, ","))
+	}
+
+	if len(opt.join) != 0 {
+		if _, err := parseJoinTag(opt.join); err != nil {
+			return err
+		}
+	}
+
+	if len(opt.defaultValue) != 0 && !strings.HasPrefix(opt.defaultValue, defaultValueCtxPrefix) {
+		if _, err := loadDefault(context.Background(), typ, opt); err != nil {
+			return err
+		}
+	}
+
+	switch opt.name {
+	case "sort_by":
+		return expectKind(opt.name, typ, reflect.String)
+	case "group_by":
+		if len(opt.allow) == 0 {
+			return errors.New("group_by requires allow")
+		}
+		return expectKind(opt.name, typ, reflect.String)
+	case "page", "page_size":
+		return expectKind(opt.name, typ, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64)
+	case "no_count":
+		return nil
+	case "select":
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String {
+			return nil
+		}
+		return expectKind(opt.name, typ, reflect.String)
+	case "trashed":
+		return expectKind(opt.name, typ, reflect.String)
+	case "preload":
+		if len(opt.allow) == 0 {
+			return errors.New("preload requires allow")
+		}
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String {
+			return nil
+		}
+		return expectKind(opt.name, typ, reflect.String)
+	}
+
+	switch opt.op {
+	case "in", "not_in":
+		return expectKind("op:"+opt.op, typ, reflect.Slice, reflect.Array)
+	case "like", "like_or":
+		return expectKind("op:"+opt.op, typ, reflect.String)
+	case "between":
+		if typ.Implements(sessionRangeType) {
+			return nil
+		}
+		return expectKind("op:"+opt.op, typ, reflect.Slice, reflect.Array)
+	case "json_eq":
+		if len(opt.path) == 0 {
+			return errors.New("op:json_eq requires path")
+		}
+	case "json_has_key":
+		if len(opt.path) == 0 {
+			return expectKind("op:json_has_key", typ, reflect.String)
+		}
+	case "json_length_gt":
+		return expectKind("op:json_length_gt", typ, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64)
+	case "match":
+		if opt.match.Mode != "" && opt.match.Mode != MatchModeNatural && opt.match.Mode != MatchModeBoolean {
+			return errors.Errorf("op:match unknown mode %s", opt.match.Mode)
+		}
+	case "date":
+		if typ == timeType {
+			return nil
+		}
+		return expectKind("op:"+opt.op, typ, reflect.String)
+	}
+
+	if len(opt.path) != 0 && !strings.HasPrefix(opt.path, "$") {
+		return errors.Errorf("invalid json path %s", opt.path)
+	}
+
+	if _, ok := getOperator(opt.op); !ok && !strings.Contains(opt.op, "?") {
+		return errors.Errorf("unknown op %s", opt.op)
+	}
+	return nil
+}
+
+func expectKind(name string, typ reflect.Type, kinds ...reflect.Kind) error {
+	for _, kind := range kinds {
+		if typ.Kind() == kind {
+			return nil
+		}
+	}
+	return errors.Errorf("%s does not support type %s", name, typ)
+}
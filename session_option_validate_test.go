@@ -0,0 +1,79 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type valid struct {
+		Name    string      `session:"name,op:like"`
+		Ids     []int64     `session:"id,op:in"`
+		Created Range[int]  `session:"created_at,op:between"`
+		Limit   int         `session:"limit,default:10"`
+		Tenant  string      `session:"tenant_id,default:ctx:tenant_id"`
+		GroupBy string      `session:"group_by,allow:status|user_id"`
+		Raw     interface{} `session:"score,op:score > ?"`
+	}
+	type unknownOp struct {
+		Amount int `session:"amount,op:gtee"`
+	}
+	type badDefault struct {
+		Limit int `session:"limit,default:ten"`
+	}
+	type inOnScalar struct {
+		Id int64 `session:"id,op:in"`
+	}
+	type unknownModifier struct {
+		Name string `session:"name,emtpy"`
+	}
+
+	cases := []struct {
+		name  string
+		typ   reflect.Type
+		error string
+	}{
+		{"valid", reflect.TypeOf(valid{}), ""},
+		{"unknown op", reflect.TypeOf(unknownOp{}), "unknown op gtee"},
+		{"bad default", reflect.TypeOf(badDefault{}), "default ten"},
+		{"in on scalar", reflect.TypeOf(inOnScalar{}), "op:in does not support type int64"},
+		{"unknown modifier", reflect.TypeOf(unknownModifier{}), "unknown modifier emtpy"},
+	}
+	for _, c := range cases {
+		err := Validate(c.typ)
+		if len(c.error) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%s: got %v, want error containing %q", c.name, err, c.error)
+		}
+	}
+}
+
+func TestParseSessionOptionE(t *testing.T) {
+	type query struct {
+		Amount int `session:"amount,op:gtee"`
+	}
+	if _, err := ParseSessionOptionE(&query{Amount: 1}); err == nil {
+		t.Fatal("expected an error for an unknown op")
+	}
+	if _, err := ParseSessionOptionE(1); err == nil {
+		t.Fatal("expected an error for a non-struct")
+	}
+}
+
+func TestMustRegisterQueryPanics(t *testing.T) {
+	type query struct {
+		Id int64 `session:"id,op:in"`
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected MustRegisterQuery to panic")
+		}
+	}()
+	MustRegisterQuery(query{})
+}
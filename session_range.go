@@ -0,0 +1,38 @@
+package database
+
+import "reflect"
+
+// Range 区间查询条件，From/To 为零值（指针类型为 nil）时忽略对应的边界，默认包含边界
+type Range[T any] struct {
+	From        T    `json:"from"`
+	To          T    `json:"to"`
+	ExcludeFrom bool `json:"exclude_from"`
+	ExcludeTo   bool `json:"exclude_to"`
+}
+
+type sessionRange interface {
+	bounds() (from, to interface{}, excludeFrom, excludeTo bool)
+}
+
+func (r Range[T]) bounds() (interface{}, interface{}, bool, bool) {
+	return r.From, r.To, r.ExcludeFrom, r.ExcludeTo
+}
+
+func loadRange(value reflect.Value) (from, to interface{}, excludeFrom, excludeTo bool, ok bool) {
+	if r, isRange := value.Interface().(sessionRange); isRange {
+		from, to, excludeFrom, excludeTo = r.bounds()
+		return from, to, excludeFrom, excludeTo, true
+	}
+
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		if value.Len() > 0 {
+			from = value.Index(0).Interface()
+		}
+		if value.Len() > 1 {
+			to = value.Index(1).Interface()
+		}
+		return from, to, false, false, true
+	}
+
+	return nil, nil, false, false, false
+}
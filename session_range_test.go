@@ -0,0 +1,73 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsZero(t *testing.T) {
+	zero, one := 0, 1
+	var nilInt *int
+	cases := []struct {
+		name  string
+		value interface{}
+		zero  bool
+	}{
+		{"nil", nil, true},
+		{"zero int", 0, true},
+		{"int", 1, false},
+		{"empty string", "", true},
+		{"zero time", time.Time{}, true},
+		{"time", time.Now(), false},
+		{"nil pointer", nilInt, true},
+		{"pointer to zero", &zero, false},
+		{"pointer", &one, false},
+	}
+	for _, c := range cases {
+		if got := isZero(c.value); got != c.zero {
+			t.Errorf("%s: isZero = %v, want %v", c.name, got, c.zero)
+		}
+	}
+}
+
+func TestOmitEmptyRange(t *testing.T) {
+	zero := 0
+	cases := []struct {
+		name  string
+		value interface{}
+		omit  bool
+	}{
+		{"empty", Range[int]{}, true},
+		{"from", Range[int]{From: 1}, false},
+		{"to", Range[int]{To: 1}, false},
+		{"nil pointers", Range[*int]{}, true},
+		{"pointer to zero", Range[*int]{From: &zero}, false},
+	}
+	for _, c := range cases {
+		if got := omitEmpty(reflect.ValueOf(c.value), sessionOptionTag{}); got != c.omit {
+			t.Errorf("%s: omitEmpty = %v, want %v", c.name, got, c.omit)
+		}
+	}
+}
+
+func TestLoadRange(t *testing.T) {
+	from, to, excludeFrom, excludeTo, ok := loadRange(reflect.ValueOf(Range[int]{From: 1, To: 2, ExcludeTo: true}))
+	if !ok || from != 1 || to != 2 || excludeFrom || !excludeTo {
+		t.Errorf("Range: got %v %v %v %v %v", from, to, excludeFrom, excludeTo, ok)
+	}
+
+	from, to, _, _, ok = loadRange(reflect.ValueOf([]int{3, 4}))
+	if !ok || from != 3 || to != 4 {
+		t.Errorf("slice: got %v %v %v", from, to, ok)
+	}
+
+	from, to, _, _, ok = loadRange(reflect.ValueOf([]int{3}))
+	if !ok || from != 3 || to != nil {
+		t.Errorf("short slice: got %v %v %v", from, to, ok)
+	}
+
+	if _, _, _, _, ok = loadRange(reflect.ValueOf(5)); ok {
+		t.Errorf("scalar: want ok = false")
+	}
+}
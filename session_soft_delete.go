@@ -0,0 +1,53 @@
+package database
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+const (
+	TrashedWith = "with"
+	TrashedOnly = "only"
+
+	defaultDeletedAtColumn = "deleted_at"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// ErrNotSoftDelete Restore 的 model 没有 gorm.DeletedAt 字段，不支持软删除
+var ErrNotSoftDelete = errors.New("model has no soft delete field")
+
+// trashedCondition 软删除列 IS NOT NULL，构造 sql 时才能拿到 model 的 schema，按 gorm.DeletedAt 字段找到软删除列
+type trashedCondition struct{}
+
+func (trashedCondition) Build(builder clause.Builder) {
+	column := defaultDeletedAtColumn
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		column = deletedAtColumn(stmt.Schema)
+	}
+	builder.WriteQuoted(clause.Column{Table: clause.CurrentTable, Name: column})
+	builder.WriteString(" IS NOT NULL")
+}
+
+func deletedAtColumn(s *schema.Schema) string {
+	if field := deletedAtField(s); field != nil {
+		return field.DBName
+	}
+	return defaultDeletedAtColumn
+}
+
+// deletedAtField model 中 gorm.DeletedAt 类型的字段，没有时返回 nil
+func deletedAtField(s *schema.Schema) *schema.Field {
+	if s == nil {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAtType && len(field.DBName) != 0 {
+			return field
+		}
+	}
+	return nil
+}
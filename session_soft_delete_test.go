@@ -0,0 +1,58 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestDeletedAtField(t *testing.T) {
+	type post struct {
+		ID        int64
+		RemovedAt gorm.DeletedAt
+	}
+	type embedded struct {
+		gorm.Model
+		Title string
+	}
+
+	cases := []struct {
+		name   string
+		model  interface{}
+		column string
+		field  bool
+	}{
+		{"custom column", &post{}, "removed_at", true},
+		{"gorm.Model", &embedded{}, "deleted_at", true},
+		{"no soft delete", &testOrder{}, defaultDeletedAtColumn, false},
+	}
+	db := newTestDB(t, DialectMySQL)
+	for _, c := range cases {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(c.model); err != nil {
+			t.Fatal(err)
+		}
+		if got := deletedAtField(stmt.Schema) != nil; got != c.field {
+			t.Errorf("%s: has field = %v, want %v", c.name, got, c.field)
+		}
+		if got := deletedAtColumn(stmt.Schema); got != c.column {
+			t.Errorf("%s: column = %s, want %s", c.name, got, c.column)
+		}
+	}
+	if deletedAtField(nil) != nil || deletedAtColumn(nil) != defaultDeletedAtColumn {
+		t.Errorf("nil schema must fall back to %s", defaultDeletedAtColumn)
+	}
+}
+
+func TestTrashedCondition(t *testing.T) {
+	type post struct {
+		ID        int64
+		RemovedAt gorm.DeletedAt
+	}
+	db := newTestDB(t, DialectMySQL)
+	sql := findSQL(db.Unscoped(), &[]post{}, SessionOption{Process: func(session *gorm.DB) *gorm.DB {
+		return session.Where(trashedCondition{})
+	}})
+	if want := "SELECT * FROM `posts` WHERE `posts`.`removed_at` IS NOT NULL"; sql != want {
+		t.Errorf("got %s, want %s", sql, want)
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// buildSubquery 使用 opts 构造子查询，table 为表名（可带别名 "users u"）或 RegisterJoin 注册的名字，
+// 注册的 join 的 on 条件作为子查询和外层查询的关联条件
+func buildSubquery(session *gorm.DB, table string, opts []SessionOption) (*gorm.DB, error) {
+	dialect := getDialect(session)
+	inner := session.Session(&gorm.Session{NewDB: true})
+
+	joinsMu.RLock()
+	join, ok := joins[table]
+	joinsMu.RUnlock()
+	if !ok {
+		join = &JoinSpec{}
+		target := strings.Fields(table)
+		if len(target) == 0 {
+			return nil, errors.New("subquery: table is empty")
+		}
+		join.Table = target[0]
+		if len(target) > 1 {
+			join.Alias = target[len(target)-1]
+		}
+	}
+
+	from := dialect.Quote(join.Table)
+	if len(join.Alias) != 0 {
+		from += " AS " + dialect.Quote(join.Alias)
+	}
+	inner = inner.Table(from)
+	if len(join.Conditions) != 0 {
+		inner = inner.Where(strings.Join(join.Conditions, " AND "), join.Args...)
+	}
+
+	for _, opt := range opts {
+		switch opt.Type {
+		case sessionOptionLimitation, sessionOptionOrderBy, sessionOptionPreload, sessionOptionUpdateCols,
+			sessionOptionCountSelect, sessionOptionNoCount:
+			continue
+		}
+		inner = opt.Process(inner)
+	}
+	return inner, nil
+}
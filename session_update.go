@@ -0,0 +1,58 @@
+package database
+
+import "reflect"
+
+// sessionSpecialNames 查询 struct 中不是列名的 session tag
+var sessionSpecialNames = map[string]bool{
+	"sort_by":   true,
+	"group_by":  true,
+	"no_count":  true,
+	"page":      true,
+	"page_size": true,
+	"select":    true,
+	"preload":   true,
+	"trashed":   true,
+}
+
+// column 字段对应的列名，没有 session tag 时使用 CamelToSnake(字段名)
+func (field sessionFieldPlan) column() (string, bool) {
+	if !field.exported || sessionSpecialNames[field.opt.name] || len(field.tag.Columns) > 1 {
+		return "", false
+	}
+	if len(field.opt.name) == 0 {
+		return CamelToSnake(field.fieldName), true
+	}
+	return field.opt.name, true
+}
+
+// BuildUpdateMap 按 session tag 生成更新的 列名 -> 值，忽略 no-update 和 - 的字段，
+// 零值只有带 empty 时才更新，指针字段不为 nil 时总是更新
+func BuildUpdateMap(data interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	elem := GetElem(reflect.ValueOf(data))
+	if elem.Kind() != reflect.Struct {
+		return result
+	}
+
+	for _, field := range getSessionPlan(elem.Type()) {
+		column, ok := field.column()
+		if !ok || field.opt.noUpdate {
+			continue
+		}
+
+		value := elem.Field(field.index)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			result[column] = value.Elem().Interface()
+			continue
+		}
+		if value.IsZero() && !field.opt.empty {
+			continue
+		}
+		result[column] = value.Interface()
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildUpdateMap(t *testing.T) {
+	type form struct {
+		Name     string  `session:"name"`
+		Remark   string  `session:"remark,empty"`
+		Stock    int     `session:"stock"`
+		Price    *int    `session:"price"`
+		Discount *int    `session:"discount"`
+		Nickname string  // 没有 tag 时使用 snake_case 的字段名
+		Creator  string  `session:"creator,no-update"`
+		Secret   string  `session:"-"`
+		Keyword  string  `session:"title&body,op:like_or"`
+		SortBy   string  `session:"sort_by"`
+		Page     int     `session:"page"`
+		Ratio    float64 `session:"ratio"`
+		internal string
+	}
+	zero := 0
+	data := &form{
+		Name:     "go",
+		Price:    &zero,
+		Nickname: "gopher",
+		Creator:  "tom",
+		Secret:   "x",
+		Keyword:  "k",
+		SortBy:   "-id",
+		Page:     2,
+		internal: "y",
+	}
+
+	want := map[string]interface{}{
+		"name":     "go",
+		"remark":   "",
+		"price":    0,
+		"nickname": "gopher",
+	}
+	if got := BuildUpdateMap(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := BuildUpdateMap(1); len(got) != 0 {
+		t.Errorf("non struct: got %v", got)
+	}
+}
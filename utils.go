@@ -4,11 +4,13 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
 const (
 	DbRecordExistsError = "Error 1062: Duplicate entry"
+	DefaultDateLayout   = "2006-01-02"
 )
 
 func omitEmpty(value reflect.Value, opt sessionOptionTag) bool {
@@ -16,12 +18,23 @@ func omitEmpty(value reflect.Value, opt sessionOptionTag) bool {
 		return false
 	}
 
+	if !value.IsValid() {
+		return true
+	}
+
 	switch value.Kind() {
 	case reflect.Slice, reflect.Map:
 		if value.IsNil() || value.Len() == 0 {
 			return true
 		}
 	default:
+		if t, ok := value.Interface().(time.Time); ok {
+			return !opt.empty && t.IsZero()
+		}
+		if r, ok := value.Interface().(sessionRange); ok {
+			from, to, _, _ := r.bounds()
+			return isZero(from) && isZero(to)
+		}
 		if !opt.empty && value.Interface() == reflect.Zero(value.Type()).Interface() {
 			return true
 		}
@@ -29,6 +42,19 @@ func omitEmpty(value reflect.Value, opt sessionOptionTag) bool {
 	return false
 }
 
+// isZero 区间边界是否未设置：非指针按零值判断，指针只要非 nil 就算已设置（&0 也是有效边界）
+func isZero(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
 func loadString(value reflect.Value, opt sessionOptionTag) string {
 	result := ""
 	if value.Kind() == reflect.String {
@@ -42,6 +68,45 @@ func loadString(value reflect.Value, opt sessionOptionTag) string {
 	return result
 }
 
+func loadSlice(value reflect.Value) []interface{} {
+	result := make([]interface{}, value.Len())
+	for i := 0; i != value.Len(); i++ {
+		result[i] = value.Index(i).Interface()
+	}
+	return result
+}
+
+// loadAllowed 客户端传入的名字（逗号分隔或字符串切片），只保留 allow 中允许的
+func loadAllowed(value interface{}, opt sessionOptionTag) (allowed []string, denied []string) {
+	names := make([]string, 0)
+	switch v := value.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []string:
+		names = v
+	}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		ok := false
+		for _, allow := range opt.allow {
+			if allow == name {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			allowed = append(allowed, name)
+		} else {
+			denied = append(denied, name)
+		}
+	}
+	return allowed, denied
+}
+
 func loadInt(value reflect.Value, opt sessionOptionTag) int {
 	result := int(0)
 	switch value.Kind() {
@@ -58,8 +123,65 @@ func loadInt(value reflect.Value, opt sessionOptionTag) int {
 	return result
 }
 
+func (opt sessionOptionTag) getLocation() *time.Location {
+	if opt.location != nil {
+		return opt.location
+	}
+	return time.Local
+}
+
+func loadTime(value interface{}, opt sessionOptionTag) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return v, false
+		}
+		return v.In(opt.getLocation()), true
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return loadTime(*v, opt)
+	case string:
+		layout := opt.layout
+		if len(layout) == 0 {
+			layout = DefaultDateLayout
+		}
+		t, err := time.ParseInLocation(layout, v, opt.getLocation())
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// loadValue 配置了 layout/tz 时把字符串、time.Time 转换到对应时区的时间
+func loadValue(value interface{}, opt sessionOptionTag) interface{} {
+	if len(opt.layout) == 0 && opt.location == nil {
+		return value
+	}
+	if _, ok := value.(string); ok && len(opt.layout) == 0 {
+		return value
+	}
+	if t, ok := loadTime(value, opt); ok {
+		return t
+	}
+	return value
+}
+
 func isDbRecordExistsError(err error) bool {
-	return strings.Contains(err.Error(), DbRecordExistsError)
+	if err == nil {
+		return false
+	}
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	for _, dialect := range dialects {
+		if dialect.IsDuplicateError(err) {
+			return true
+		}
+	}
+	return false
 }
 
 func GetElem(elem reflect.Value) reflect.Value {
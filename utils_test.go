@@ -0,0 +1,107 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessionOptionTagTime(t *testing.T) {
+	opt := parseSessionOptionTag("paid_at,op:date,layout:2006/01/02,tz:Asia/Shanghai")
+	if opt.layout != "2006/01/02" {
+		t.Errorf("layout = %q", opt.layout)
+	}
+	if opt.location == nil || opt.location.String() != "Asia/Shanghai" {
+		t.Errorf("location = %v", opt.location)
+	}
+	if opt := parseSessionOptionTag("paid_at"); opt.getLocation() != time.Local {
+		t.Errorf("default location = %v, want Local", opt.getLocation())
+	}
+}
+
+func TestLoadTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	utc := time.Date(2026, 1, 2, 16, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		value interface{}
+		opt   sessionOptionTag
+		want  time.Time
+		ok    bool
+	}{
+		{"default layout", "2026-01-02", sessionOptionTag{location: shanghai}, time.Date(2026, 1, 2, 0, 0, 0, 0, shanghai), true},
+		{"custom layout", "2026/01/02 08:30", sessionOptionTag{layout: "2006/01/02 15:04", location: shanghai}, time.Date(2026, 1, 2, 8, 30, 0, 0, shanghai), true},
+		{"bad string", "yesterday", sessionOptionTag{}, time.Time{}, false},
+		{"time to tz", utc, sessionOptionTag{location: shanghai}, time.Date(2026, 1, 3, 0, 0, 0, 0, shanghai), true},
+		{"time pointer", &utc, sessionOptionTag{location: shanghai}, time.Date(2026, 1, 3, 0, 0, 0, 0, shanghai), true},
+		{"zero time", time.Time{}, sessionOptionTag{}, time.Time{}, false},
+		{"nil pointer", (*time.Time)(nil), sessionOptionTag{}, time.Time{}, false},
+		{"int", 20260102, sessionOptionTag{}, time.Time{}, false},
+	}
+	for _, c := range cases {
+		got, ok := loadTime(c.value, c.opt)
+		if ok != c.ok || !got.Equal(c.want) {
+			t.Errorf("%s: got %v %v, want %v %v", c.name, got, ok, c.want, c.ok)
+			continue
+		}
+		if ok && got.Location() != c.opt.getLocation() {
+			t.Errorf("%s: location = %v, want %v", c.name, got.Location(), c.opt.getLocation())
+		}
+	}
+}
+
+func TestLoadValue(t *testing.T) {
+	if got := loadValue("2026-01-02", sessionOptionTag{}); got != "2026-01-02" {
+		t.Errorf("no layout/tz: got %v", got)
+	}
+	if got := loadValue("2026-01-02", sessionOptionTag{location: time.UTC}); got != "2026-01-02" {
+		t.Errorf("tz only keeps strings: got %v", got)
+	}
+	want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	if got := loadValue("2026-01-02", sessionOptionTag{layout: DefaultDateLayout, location: time.UTC}); got != want {
+		t.Errorf("layout: got %v, want %v", got, want)
+	}
+	if got := loadValue(10, sessionOptionTag{location: time.UTC}); got != 10 {
+		t.Errorf("non time value: got %v", got)
+	}
+}
+
+func TestOmitEmptyTime(t *testing.T) {
+	if !omitEmpty(reflect.ValueOf(time.Time{}), sessionOptionTag{}) {
+		t.Errorf("zero time must be omitted")
+	}
+	if omitEmpty(reflect.ValueOf(time.Time{}), sessionOptionTag{empty: true}) {
+		t.Errorf("zero time with empty must be kept")
+	}
+	if omitEmpty(reflect.ValueOf(time.Now()), sessionOptionTag{}) {
+		t.Errorf("non zero time must be kept")
+	}
+}
+
+func TestParseSessionOptionDate(t *testing.T) {
+	type query struct {
+		Day     string        `session:"created_at,op:date,tz:UTC"`
+		Before  string        `session:"paid_at,op:lt,layout:2006/01/02,tz:UTC"`
+		Between Range[string] `session:"updated_at,op:between,layout:2006-01-02,tz:UTC"`
+	}
+	db := newTestDB(t, DialectMySQL)
+	sql := findSQL(db, &[]testOrder{}, ParseSessionOption(&query{
+		Day:     "2026-01-02",
+		Before:  "2026/01/05",
+		Between: Range[string]{From: "2026-01-01"},
+	})...)
+
+	for _, want := range []string{
+		"created_at >= '2026-01-02 00:00:00' AND created_at < '2026-01-03 00:00:00'",
+		"paid_at < '2026-01-05 00:00:00'",
+		"updated_at >= '2026-01-01 00:00:00'",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("sql %q does not contain %q", sql, want)
+		}
+	}
+}